@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/go-test/fuzz"
+)
+
+func main() {
+	var usage = `NAME
+  %s - Write random seed corpus files for each Go fuzz test <name>
+
+USAGE
+  %s [options] <name>...
+
+OPTIONS:
+`
+	flag.Usage = func() {
+		cmd := os.Args[0]
+		fmt.Fprintf(os.Stderr, usage, cmd, cmd)
+		flag.PrintDefaults()
+	}
+
+	var kindNames []string
+	for _, kind := range fuzz.Kinds() {
+		kindNames = append(kindNames, kind.String())
+	}
+
+	var (
+		dir      string
+		kindName string
+		n        int
+		seed     int64
+	)
+	flag.StringVar(&dir, "dir", filepath.Join("testdata", "fuzz"), "directory containing the corpus directory of each fuzz test")
+	flag.StringVar(&kindName, "kind", fuzz.Blocks.String(), "kind of input: "+strings.Join(kindNames, ", "))
+	flag.IntVar(&n, "n", 10, "number of inputs to write for each fuzz test")
+	flag.Int64Var(&seed, "seed", fuzz.DefaultSeed, "random seed")
+	flag.Parse()
+
+	names := flag.Args()
+	if len(names) < 1 {
+		fmt.Fprintln(os.Stderr, "missing fuzz test name")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := writeCorpus(dir, kindName, n, seed, names); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func writeCorpus(dir, kindName string, n int, seed int64, names []string) error {
+	kind, err := fuzz.ParseKind(kindName)
+	if err != nil {
+		return err
+	}
+	inputs, err := fuzz.Inputs(kind, n, seed)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err = fuzz.WriteCorpus(filepath.Join(dir, name), inputs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/stretchr/testify/require"
+)
+
+// BuildOptions are options for building a Go package with Runner.GoBuild or
+// Runner.GoInstall.
+type BuildOptions struct {
+	// Race enables the race detector.
+	Race bool
+	// Cover enables coverage instrumentation. It is always enabled if the
+	// Runner has coverage enabled by Runner.EnableCover.
+	Cover bool
+	// Tags are build tags.
+	Tags []string
+	// LDFlags are flags passed to the linker, such as "-X main.version=1.0".
+	LDFlags string
+	// Dir is the directory that the go command is run in, which determines
+	// the module that the package is built in. The current directory is used
+	// if Dir is empty.
+	Dir string
+}
+
+func (o BuildOptions) flags() []string {
+	var flags []string
+	if o.Race {
+		flags = append(flags, "-race")
+	}
+	if o.Cover {
+		flags = append(flags, "-cover")
+	}
+	if len(o.Tags) != 0 {
+		flags = append(flags, "-tags", strings.Join(o.Tags, ","))
+	}
+	if o.LDFlags != "" {
+		flags = append(flags, "-ldflags", o.LDFlags)
+	}
+	return flags
+}
+
+var (
+	buildMu    sync.Mutex
+	buildDir   string
+	buildCache = make(map[string]*build)
+)
+
+// build is a binary that is built once for all tests in a package.
+type build struct {
+	// done is closed when the build has finished.
+	done chan struct{}
+	path string
+	out  []byte
+	err  error
+}
+
+// failed returns true if the build has finished and failed, so that it must
+// be built again.
+func (b *build) failed() bool {
+	select {
+	case <-b.done:
+		return b.err != nil
+	default:
+		return false
+	}
+}
+
+// buildEnvPrefixes are the prefixes of environment variables that change
+// what the go command builds, such as GOOS, GOFLAGS and CGO_ENABLED.
+var buildEnvPrefixes = []string{"GO", "CGO_", "CC=", "CXX=", "AR=", "PKG_CONFIG="}
+
+// nonBuildEnv are the environment variables with a prefix in
+// buildEnvPrefixes that only locate directories, and so differ between tests
+// without changing what is built.
+var nonBuildEnv = []string{"GOBIN", "GOCACHE", "GOCOVERDIR", "GOENV", "GOMODCACHE", "GOPATH", "GOTMPDIR"}
+
+// buildEnv returns the environment variables in env that change what the go
+// command builds, sorted by name, with the last value of each.
+func buildEnv(env []string) []string {
+	vars := make(map[string]string)
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if slices.Contains(nonBuildEnv, name) {
+			continue
+		}
+		for _, prefix := range buildEnvPrefixes {
+			if strings.HasPrefix(kv, prefix) {
+				vars[name] = kv
+				break
+			}
+		}
+	}
+	var buildVars []string
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		buildVars = append(buildVars, vars[name])
+	}
+	return buildVars
+}
+
+// GoBuild builds the Go package pkg, which is a package path, such as
+// "github.com/ipfs/go-test/cli/random-data", or a directory, such as
+// "./cli/random-data", and returns the absolute path of the binary. The
+// binary is built once, and shared by all tests in the test binary that build
+// the same package with the same options. The test fails if the package cannot
+// be built.
+//
+// The build uses the Runner's environment, and binaries are shared only by
+// runners whose environments have the same variables that change what is
+// built, such as GOOS, GOARCH, CGO_ENABLED, GOFLAGS and GOEXPERIMENT. A build
+// that fails is tried again by the next test that builds the package. The
+// build is not canceled when ctx is, as it may be shared by other tests, but
+// GoBuild then stops waiting for it, and the test fails.
+//
+// The binaries are kept in the "go-test-cmd" directory of the user's cache
+// directory, as returned by os.UserCacheDir, in a subdirectory for each
+// package and set of options, so they are not removed when a test finishes.
+// Each test binary builds its binaries again, replacing those left by earlier
+// runs, and CleanBuildCache removes them.
+func (rnr *Runner) GoBuild(ctx context.Context, pkg string, opts BuildOptions) string {
+	rnr.t.Helper()
+	return rnr.goBuild(ctx, false, pkg, opts)
+}
+
+// GoInstall is the same as GoBuild, but builds the package using "go install",
+// which also allows pkg to have a version suffix, such as "@latest", to install
+// a package outside the current module. The binary is copied to Runner.Dir,
+// where "go install" puts binaries for commands run by Runner, and its path
+// there is returned.
+func (rnr *Runner) GoInstall(ctx context.Context, pkg string, opts BuildOptions) string {
+	rnr.t.Helper()
+
+	cached := rnr.goBuild(ctx, true, pkg, opts)
+	bin := filepath.Join(rnr.Dir, filepath.Base(cached))
+	err := copyFile(bin, cached)
+	require.NoError(rnr.t, err)
+	return bin
+}
+
+func (rnr *Runner) goBuild(ctx context.Context, install bool, pkg string, opts BuildOptions) string {
+	rnr.t.Helper()
+
+	if rnr.cover {
+		opts.Cover = true
+	}
+	dir, err := filepath.Abs(opts.Dir)
+	require.NoError(rnr.t, err)
+	name := binaryName(dir, pkg, envGOOS(rnr.Env))
+
+	// Only the environment variables that change what is built are part of
+	// the key, as the environment also differs between tests by the temporary
+	// HOME and GOBIN directories.
+	key := fmt.Sprintf("%t %s %s %q %q", install, dir, pkg, opts.flags(), buildEnv(rnr.Env))
+	buildMu.Lock()
+	if buildDir == "" {
+		buildDir = defaultBuildDir()
+	}
+	binDir := filepath.Join(buildDir, fmt.Sprintf("%x", sha256.Sum256([]byte(key)))[:16])
+	b, ok := buildCache[key]
+	if !ok || b.failed() {
+		b = &build{
+			done: make(chan struct{}),
+			path: filepath.Join(binDir, name),
+		}
+		buildCache[key] = b
+
+		// The binary is built in a temporary directory and then renamed, so
+		// that a binary left by an earlier run, which tests in another process
+		// may be running, is replaced rather than overwritten.
+		tmpDir, err := makeTempDir(binDir)
+		if err != nil {
+			b.err = err
+			close(b.done)
+		} else {
+			var cmd *exec.Cmd
+			if install {
+				args := append([]string{"install"}, opts.flags()...)
+				cmd = exec.Command("go", append(args, pkg)...)
+				cmd.Env = append(slices.Clone(rnr.Env), "GOBIN="+tmpDir)
+			} else {
+				args := append([]string{"build", "-o", filepath.Join(tmpDir, name)}, opts.flags()...)
+				cmd = exec.Command("go", append(args, pkg)...)
+				cmd.Env = slices.Clone(rnr.Env)
+			}
+			cmd.Dir = dir
+			if rnr.verbose {
+				rnr.t.Logf("run: %s", cmd)
+			}
+			go func() {
+				defer os.RemoveAll(tmpDir)
+				b.out, b.err = cmd.CombinedOutput()
+				if b.err == nil {
+					b.err = os.Rename(filepath.Join(tmpDir, name), b.path)
+				}
+				close(b.done)
+			}()
+		}
+	}
+	buildMu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		require.NoError(rnr.t, ctx.Err(), "canceled waiting for %s to build", pkg)
+	}
+	require.NoError(rnr.t, b.err, "cannot build %s: %s", pkg, b.out)
+	return b.path
+}
+
+// CleanBuildCache removes the binaries built by Runner.GoBuild and
+// Runner.GoInstall in this process. A binary that is built again, with the
+// same package, options and directory, replaces the one left by an earlier
+// run, so this is only needed by tests that build packages in temporary
+// directories. It can be called by TestMain after running the tests:
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		cmd.CleanBuildCache()
+//		os.Exit(code)
+//	}
+func CleanBuildCache() error {
+	buildMu.Lock()
+	defer buildMu.Unlock()
+
+	var errs []error
+	for _, b := range buildCache {
+		errs = append(errs, os.RemoveAll(filepath.Dir(b.path)))
+	}
+	clear(buildCache)
+	return errors.Join(errs...)
+}
+
+// defaultBuildDir returns the directory that binaries are built in, which is
+// in the user's cache directory, or the temporary directory if there is none.
+func defaultBuildDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "go-test-cmd")
+}
+
+// makeTempDir creates dir, if it does not exist, and a new temporary
+// directory in it.
+func makeTempDir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, "build-")
+}
+
+// envGOOS returns the operating system that the go command builds for with
+// the environment env.
+func envGOOS(env []string) string {
+	goos := runtime.GOOS
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "GOOS="); ok && v != "" {
+			goos = v
+		}
+	}
+	return goos
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// binaryName returns the name of the binary that the go command builds for
+// pkg, when run in dir for the operating system goos.
+func binaryName(dir, pkg, goos string) string {
+	pkg, _, _ = strings.Cut(pkg, "@")
+	var name string
+	if pkg == "." || pkg == ".." || strings.HasPrefix(pkg, "./") || strings.HasPrefix(pkg, "../") || filepath.IsAbs(pkg) {
+		if !filepath.IsAbs(pkg) {
+			pkg = filepath.Join(dir, pkg)
+		}
+		name = strings.TrimSuffix(filepath.Base(pkg), ".go")
+	} else {
+		// The go command names the binary of a module with a major version
+		// suffix, such as example.com/cmd/v2, after the element before it.
+		name = path.Base(pkg)
+		if majorVersion.MatchString(name) {
+			name = path.Base(path.Dir(pkg))
+		}
+	}
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
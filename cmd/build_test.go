@@ -0,0 +1,140 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-test/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	cmd.CleanBuildCache()
+	os.Exit(code)
+}
+
+var versionSrc = `
+package main
+
+import "fmt"
+
+var version = "dev"
+
+var tagged = "untagged"
+
+func main() {
+	fmt.Println(version, tagged)
+}
+`
+
+var taggedSrc = `
+//go:build extra
+
+package main
+
+func init() {
+	tagged = "tagged"
+}
+`
+
+// writeVersionModule writes the example.com/version module, and returns its
+// directory.
+func writeVersionModule(t *testing.T) string {
+	modDir := filepath.Join(t.TempDir(), "version")
+	require.NoError(t, os.Mkdir(modDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modDir, "go.mod"), []byte("module example.com/version\n\ngo 1.21\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(modDir, "main.go"), []byte(versionSrc), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(modDir, "tagged.go"), []byte(taggedSrc), 0644))
+	return modDir
+}
+
+func TestGoBuild(t *testing.T) {
+	modDir := writeVersionModule(t)
+
+	dir := t.TempDir()
+	r := cmd.NewRunner(t, dir)
+	ctx := context.Background()
+
+	bin := r.GoBuild(ctx, ".", cmd.BuildOptions{Dir: modDir})
+	require.True(t, filepath.IsAbs(bin))
+	require.Equal(t, "dev untagged\n", string(r.Run(ctx, bin)))
+
+	opts := cmd.BuildOptions{
+		Dir:     modDir,
+		Tags:    []string{"extra"},
+		LDFlags: "-X main.version=1.2.3",
+		Cover:   true,
+	}
+	built := r.GoBuild(ctx, ".", opts)
+	require.NotEqual(t, bin, built)
+	require.Equal(t, "1.2.3 tagged\n", string(r.RunWithResult(ctx, built).ExpectSuccess().Stdout))
+
+	// The binary is built once, and shared by other tests.
+	info, err := os.Stat(built)
+	require.NoError(t, err)
+	t.Run("cached", func(t *testing.T) {
+		r := cmd.NewRunner(t, t.TempDir())
+		require.Equal(t, built, r.GoBuild(ctx, ".", opts))
+		again, err := os.Stat(built)
+		require.NoError(t, err)
+		require.Equal(t, info.ModTime(), again.ModTime())
+
+		installed := r.GoInstall(ctx, "example.com/version", opts)
+		require.Equal(t, filepath.Join(r.Dir, filepath.Base(built)), installed)
+		require.Equal(t, "1.2.3 tagged\n", string(r.RunWithResult(ctx, installed).ExpectSuccess().Stdout))
+	})
+
+	bin = r.GoBuild(ctx, "github.com/ipfs/go-test/cli/random-data", cmd.BuildOptions{})
+	require.Equal(t, "random-data", strings.TrimSuffix(filepath.Base(bin), ".exe"))
+	require.FileExists(t, bin)
+}
+
+func TestGoBuildEnv(t *testing.T) {
+	modDir := writeVersionModule(t)
+	ctx := context.Background()
+
+	r := cmd.NewRunner(t, t.TempDir())
+	bin := r.GoBuild(ctx, ".", cmd.BuildOptions{Dir: modDir})
+
+	// Variables that change what is built are part of the cache key, but
+	// the runner's directories are not.
+	r = cmd.NewRunner(t, t.TempDir())
+	require.Equal(t, bin, r.GoBuild(ctx, ".", cmd.BuildOptions{Dir: modDir}))
+	r.Env = append(r.Env, "GOFLAGS=-tags=extra")
+	tagged := r.GoBuild(ctx, ".", cmd.BuildOptions{Dir: modDir})
+	require.NotEqual(t, bin, tagged)
+	require.Equal(t, "dev tagged\n", string(r.Run(ctx, tagged)))
+
+	// The binary is named for the operating system that it is built for.
+	r = cmd.NewRunner(t, t.TempDir())
+	r.Env = append(r.Env, "GOOS=windows", "GOARCH=amd64")
+	exe := r.GoBuild(ctx, ".", cmd.BuildOptions{Dir: modDir})
+	require.Equal(t, "version.exe", filepath.Base(exe))
+	require.FileExists(t, exe)
+}
+
+func TestGoBuildRetry(t *testing.T) {
+	if modDir := os.Getenv("TEST_GO_BUILD_RETRY"); modDir != "" {
+		t.Run("broken", func(t *testing.T) {
+			cmd.NewRunner(t, t.TempDir()).GoBuild(context.Background(), ".", cmd.BuildOptions{Dir: modDir})
+		})
+		require.NoError(t, os.WriteFile(filepath.Join(modDir, "main.go"), []byte(versionSrc), 0644))
+		t.Run("fixed", func(t *testing.T) {
+			cmd.NewRunner(t, t.TempDir()).GoBuild(context.Background(), ".", cmd.BuildOptions{Dir: modDir})
+		})
+		return
+	}
+
+	modDir := writeVersionModule(t)
+	require.NoError(t, os.WriteFile(filepath.Join(modDir, "main.go"), []byte("package main\n\nfunc main() {"), 0644))
+	r := cmd.NewRunner(t, t.TempDir())
+	r.Env = append(r.Env, "TEST_GO_BUILD_RETRY="+modDir)
+	res := r.RunWithResult(context.Background(), os.Args[0], "-test.run=^TestGoBuildRetry$", "-test.v")
+	res.ExpectFailure()
+	res.ExpectStdoutContains("--- FAIL: TestGoBuildRetry/broken")
+	res.ExpectStdoutContains("--- PASS: TestGoBuildRetry/fixed")
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+)
+
+// CoverOptions are options for collecting coverage data from commands, with
+// Runner.EnableCover.
+type CoverOptions struct {
+	// Profile is the path of a text coverage profile, in the format written
+	// by "go test -coverprofile", that the coverage data is written to when
+	// the test finishes. No profile is written if Profile is empty.
+	//
+	// The coverage of commands is not included in the coverage that "go
+	// test -cover" reports, even with -coverpkg, as the test binary only
+	// reports its own coverage data. The profile can be combined with the
+	// profile written by "go test -coverprofile" by appending all but its
+	// first line, which is the "mode:" line.
+	Profile string
+}
+
+// EnableCover collects coverage data from the commands that Runner runs and
+// starts, and returns the directory that the coverage data is written to.
+// Binaries built by GoBuild and GoInstall are built with -cover, and the
+// GOCOVERDIR environment variable of commands is set to a directory for the
+// test. Call EnableCover before starting any commands, so that the commands
+// have been stopped and have written their coverage data when the test
+// finishes. When the test finishes, the coverage percentage of each package is
+// logged, and the coverage profile is written as set by opts.
+func (rnr *Runner) EnableCover(opts CoverOptions) string {
+	rnr.t.Helper()
+
+	dir := rnr.t.TempDir()
+	rnr.Env = append(rnr.Env, "GOCOVERDIR="+dir)
+	rnr.cover = true
+	rnr.t.Cleanup(func() {
+		rnr.collectCover(dir, opts)
+	})
+	return dir
+}
+
+// collectCover reports the coverage data in dir, and writes its profile.
+func (rnr *Runner) collectCover(dir string, opts CoverOptions) {
+	rnr.t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		rnr.t.Error(err)
+		return
+	}
+	if len(entries) == 0 {
+		rnr.t.Log("no coverage data was written by commands")
+		return
+	}
+
+	out, err := covdata("percent", "-i="+dir)
+	if err != nil {
+		rnr.t.Errorf("cannot get coverage percentage: %s: %s", err, out)
+		return
+	}
+	rnr.t.Logf("coverage of commands:\n%s", out)
+
+	if opts.Profile != "" {
+		out, err = covdata("textfmt", "-i="+dir, "-o="+opts.Profile)
+		if err != nil {
+			rnr.t.Errorf("cannot write coverage profile: %s: %s", err, out)
+		}
+	}
+}
+
+func covdata(args ...string) ([]byte, error) {
+	return exec.Command("go", append([]string{"tool", "covdata"}, args...)...).CombinedOutput()
+}
@@ -0,0 +1,52 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-test/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnableCover(t *testing.T) {
+	if modDir := os.Getenv("TEST_ENABLE_COVER"); modDir != "" {
+		r := cmd.NewRunner(t, t.TempDir())
+		coverDir := r.EnableCover(cmd.CoverOptions{Profile: filepath.Join(modDir, "cover.out")})
+		ctx := context.Background()
+
+		bin := r.GoBuild(ctx, ".", cmd.BuildOptions{Dir: modDir})
+		res := r.RunWithResult(ctx, bin).ExpectSuccess()
+		require.Empty(t, res.Stderr)
+
+		p := r.StartProcess(ctx, cmd.Args(bin))
+		require.NoError(t, p.Wait())
+
+		entries, err := os.ReadDir(coverDir)
+		require.NoError(t, err)
+		require.NotEmpty(t, entries)
+		return
+	}
+
+	modDir := writeVersionModule(t)
+	r := cmd.NewRunner(t, t.TempDir())
+	r.Env = append(r.Env, "TEST_ENABLE_COVER="+modDir)
+	res := r.RunWithResult(context.Background(), os.Args[0], "-test.run=^TestEnableCover$", "-test.v")
+	res.ExpectSuccess()
+	// The coverage of the command's package is reported when the test
+	// finishes.
+	res.ExpectStdoutContains("coverage of commands:")
+	res.ExpectStdoutContains("example.com/version")
+	res.ExpectStdoutContains("coverage: 100.0% of statements")
+
+	data, err := os.ReadFile(filepath.Join(modDir, "cover.out"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "mode: set\n")
+	require.Contains(t, string(data), "example.com/version/main.go:")
+}
+
+func TestEnableCoverNoData(t *testing.T) {
+	r := cmd.NewRunner(t, t.TempDir())
+	r.EnableCover(cmd.CoverOptions{Profile: filepath.Join(t.TempDir(), "cover.out")})
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultProbeInterval is how long a probe waits between checks, unless
+	// set by Probe.WithInterval.
+	DefaultProbeInterval = 100 * time.Millisecond
+	// DefaultProbeTimeout is how long a probe checks for readiness before
+	// failing, unless set by Probe.WithTimeout.
+	DefaultProbeTimeout = 30 * time.Second
+)
+
+// Probe checks whether a started process is ready, by checking repeatedly
+// until the check succeeds or the probe times out. Probes are used with
+// Process.WaitReady or CmdArgs.WithReady.
+type Probe struct {
+	desc     string
+	check    func(ctx context.Context, p *Process) error
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// TCPProbe creates a Probe that is ready when a TCP connection can be made to
+// the address.
+func TCPProbe(addr string) Probe {
+	return newProbe("tcp "+addr, func(ctx context.Context, p *Process) error {
+		return dial(ctx, "tcp", addr)
+	})
+}
+
+// UnixSocketProbe creates a Probe that is ready when a connection can be made
+// to the unix socket at path. A relative path is relative to Runner.Dir.
+func UnixSocketProbe(path string) Probe {
+	return newProbe("unix socket "+path, func(ctx context.Context, p *Process) error {
+		return dial(ctx, "unix", p.rnr.path(path))
+	})
+}
+
+// HTTPProbe creates a Probe that is ready when a GET request to the url
+// returns the status code. If status is 0, then the status code must be 200.
+func HTTPProbe(url string, status int) Probe {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return newProbe("http "+url, func(ctx context.Context, p *Process) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		rsp.Body.Close()
+		if rsp.StatusCode != status {
+			return fmt.Errorf("got status %d, expected %d", rsp.StatusCode, status)
+		}
+		return nil
+	})
+}
+
+// FileProbe creates a Probe that is ready when a file exists at path. A
+// relative path is relative to Runner.Dir.
+func FileProbe(path string) Probe {
+	return newProbe("file "+path, func(ctx context.Context, p *Process) error {
+		_, err := os.Stat(p.rnr.path(path))
+		return err
+	})
+}
+
+func newProbe(desc string, check func(ctx context.Context, p *Process) error) Probe {
+	return Probe{
+		desc:     desc,
+		check:    check,
+		interval: DefaultProbeInterval,
+		timeout:  DefaultProbeTimeout,
+	}
+}
+
+// WithInterval returns a copy of the probe that waits for the interval
+// between checks. Each check is also given no longer than the interval to
+// complete, so that a check that hangs is retried. If interval is not
+// positive, then DefaultProbeInterval is used.
+func (pr Probe) WithInterval(interval time.Duration) Probe {
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	pr.interval = interval
+	return pr
+}
+
+// WithTimeout returns a copy of the probe that fails if it is not ready
+// within the timeout. If timeout is not positive, then DefaultProbeTimeout is
+// used.
+func (pr Probe) WithTimeout(timeout time.Duration) Probe {
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	pr.timeout = timeout
+	return pr
+}
+
+func (pr Probe) String() string {
+	return pr.desc
+}
+
+// wait checks the probe until it is ready, the probe times out, the context
+// is canceled, or the process exits, as seen by its output being closed. Each
+// check has a deadline of the probe's interval, or of the probe's timeout if
+// that is sooner.
+func (pr Probe) wait(ctx context.Context, p *Process) error {
+	ctx, cancel := context.WithTimeout(ctx, pr.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pr.interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		err := pr.checkOnce(ctx, p)
+		if err == nil {
+			return nil
+		}
+		// Keep the error of the last complete check, rather than one that was
+		// interrupted by the timeout.
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+		select {
+		case <-ticker.C:
+		case <-p.outputDone:
+			// Check once more, in case the process became ready and exited.
+			if pr.checkOnce(ctx, p) == nil {
+				return nil
+			}
+			return fmt.Errorf("%w: %w", ErrProcessExited, lastErr)
+		case <-ctx.Done():
+			return fmt.Errorf("%w, last check failed: %w", ctx.Err(), lastErr)
+		}
+	}
+}
+
+// checkOnce checks the probe, giving the check no longer than the interval.
+func (pr Probe) checkOnce(ctx context.Context, p *Process) error {
+	ctx, cancel := context.WithTimeout(ctx, pr.interval)
+	defer cancel()
+	return pr.check(ctx, p)
+}
+
+// WaitReady waits for all of the probes to be ready. It returns an error if a
+// probe is not ready within its timeout, if the context is canceled, or if the
+// process exits. The error includes the last lines of the process's output.
+func (p *Process) WaitReady(ctx context.Context, probes ...Probe) error {
+	for _, pr := range probes {
+		if err := pr.wait(ctx, p); err != nil {
+			return fmt.Errorf("%s is not ready: %s: %w\nlast %d lines of output:\n%s",
+				p.name, pr, err, tailLines, p.tail.String())
+		}
+	}
+	return nil
+}
+
+func dial(ctx context.Context, network, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// path returns the path relative to Dir, if it is not absolute.
+func (rnr *Runner) path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(rnr.Dir, path)
+}
@@ -0,0 +1,150 @@
+package cmd_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-test/cmd"
+	"github.com/ipfs/go-test/ports"
+	"github.com/stretchr/testify/require"
+)
+
+var serveSrc = `
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt)
+
+	fmt.Println("starting")
+	time.Sleep(200 * time.Millisecond)
+
+	if os.Args[2] != "" {
+		l, err := net.Listen("unix", os.Args[2])
+		if err != nil {
+			panic(err)
+		}
+		defer l.Close()
+	}
+	if err := os.WriteFile(os.Args[3], nil, 0644); err != nil {
+		panic(err)
+	}
+	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	fmt.Println("serving")
+	go func() {
+		if err := http.ListenAndServe("127.0.0.1:"+os.Args[1], nil); err != nil {
+			panic(err)
+		}
+	}()
+	<-shutdown
+}
+`
+
+func TestProbes(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, serveSrc)
+	r := cmd.NewRunner(t, dir)
+
+	port := ports.Reserve(t, "tcp", ports.Loopback4)
+	addr := port.Addr()
+	socket := ""
+	probes := []cmd.Probe{
+		cmd.TCPProbe(addr),
+		cmd.HTTPProbe("http://"+addr+"/health", 204).WithInterval(10 * time.Millisecond),
+		cmd.FileProbe("ready"),
+		cmd.FileProbe(filepath.Join(dir, "ready")),
+	}
+	if runtime.GOOS != "windows" {
+		socket = filepath.Join(dir, "s.sock")
+		probes = append(probes, cmd.UnixSocketProbe("s.sock"))
+	}
+
+	args := cmd.Args(bin, strconv.Itoa(port.Release()), socket, filepath.Join(dir, "ready"))
+	p := r.StartProcess(context.Background(), args.WithReady(probes...))
+	require.True(t, p.Running())
+	require.Eventually(t, func() bool {
+		return strings.Contains(string(p.Stdout()), "serving\n")
+	}, time.Second, 10*time.Millisecond)
+
+	ctx := context.Background()
+	require.NoError(t, p.WaitReady(ctx, probes...))
+
+	err := p.WaitReady(ctx, cmd.HTTPProbe("http://"+addr+"/missing", 0).WithTimeout(100*time.Millisecond))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "got status 404, expected 200")
+	require.ErrorContains(t, err, "http://"+addr+"/missing")
+	require.ErrorContains(t, err, "last 50 lines of output:\nstarting\nserving")
+
+	p.Stop(5 * time.Second)
+	err = p.WaitReady(ctx, cmd.TCPProbe(addr))
+	require.ErrorIs(t, err, cmd.ErrProcessExited)
+}
+
+func TestProbeExited(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, repeatSrc)
+	r := cmd.NewRunner(t, dir)
+
+	p := r.StartProcess(context.Background(), cmd.Args(bin, "tick", "3"))
+	start := time.Now()
+	err := p.WaitReady(context.Background(), cmd.FileProbe("never"))
+	require.ErrorIs(t, err, cmd.ErrProcessExited)
+	require.ErrorContains(t, err, "tick 3")
+	require.Less(t, time.Since(start), cmd.DefaultProbeTimeout)
+}
+
+func TestProbeHangingCheck(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, failSrc)
+	r := cmd.NewRunner(t, dir)
+
+	// The server accepts connections, but never responds.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			defer conn.Close()
+		}
+	}()
+
+	w := cmd.NewWatcher("starting")
+	p := r.StartProcess(context.Background(), cmd.Args(bin, "wait"), w)
+	require.NoError(t, w.Wait(context.Background()))
+
+	probe := cmd.HTTPProbe("http://"+l.Addr().String()+"/", 0).
+		WithInterval(50 * time.Millisecond).
+		WithTimeout(500 * time.Millisecond)
+	err = p.WaitReady(context.Background(), probe)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Greater(t, accepted.Load(), int32(1), "hanging check was not retried")
+
+	// Intervals and timeouts that are not positive are replaced by the
+	// defaults.
+	require.NoError(t, p.WaitReady(context.Background(), cmd.FileProbe(bin).WithInterval(0).WithTimeout(-time.Second)))
+	p.Stop(5 * time.Second)
+}
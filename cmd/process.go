@@ -0,0 +1,558 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// tailLines is the number of lines of output shown when a process fails.
+	tailLines = 50
+	// failureDelay is how long to wait, after a process outputs a panic or
+	// race report, for the rest of the report to be output before failing the
+	// test.
+	failureDelay = 500 * time.Millisecond
+	// waitDelay is how long to wait for output to be closed after a process
+	// exits.
+	waitDelay = time.Second
+	// outputLimit is the number of bytes of each of stdout and stderr that a
+	// Process keeps.
+	outputLimit = 1 << 20
+	// cleanupTimeout is how long to wait, at the end of a test, for a process
+	// that is still running to exit after each stop step.
+	cleanupTimeout = 5 * time.Second
+)
+
+// ErrProcessExited is returned when waiting for a watcher whose process has
+// exited without the watcher matching.
+var ErrProcessExited = errors.New("process exited")
+
+// failurePrefixes are the beginnings of lines that indicate a Go program has
+// failed.
+var failurePrefixes = []string{"panic:", "fatal error:", "WARNING: DATA RACE"}
+
+// Process is a command started by Runner.StartProcess, or by Runner.Start and
+// then passed to Runner.Process, which is waited for until it exits. The
+// methods of Process fail the test if there is an error, as do the methods of
+// Runner.
+type Process struct {
+	rnr  *Runner
+	ctx  context.Context
+	args CmdArgs
+	name string
+	cmd  *exec.Cmd
+
+	watchers  []Watcher
+	stdout    *lineWriter
+	stderr    *lineWriter
+	stdoutBuf outputBuffer
+	stderrBuf outputBuffer
+	tail      tailBuffer
+	// stdoutR and stderrR are the read ends of the pipes that the command
+	// writes its output to, and stdoutW and stderrW are the write ends, which
+	// are closed once the command has started.
+	stdoutR, stdoutW *os.File
+	stderrR, stderrW *os.File
+	// outputDone is closed once both stdout and stderr have been read to EOF,
+	// which is when the process, and any processes it started, have exited or
+	// closed their output.
+	outputDone chan struct{}
+
+	// monitored is true if the process is expected not to exit until it is
+	// stopped, as it was started by Runner.StartProcess.
+	monitored bool
+	// waiting is true once the process is being waited for by monitor.
+	waiting  atomic.Bool
+	stopping atomic.Bool
+	done     chan struct{}
+	// err is the error returned by cmd.Wait, and is set before done is
+	// closed.
+	err error
+
+	failMu   sync.Mutex
+	failure  string
+	reported bool
+}
+
+func newProcess(rnr *Runner, ctx context.Context, args CmdArgs, cmd *exec.Cmd, watchers []Watcher, monitored bool) (*Process, error) {
+	p := &Process{
+		rnr:        rnr,
+		ctx:        ctx,
+		args:       args,
+		name:       filepath.Base(args.name),
+		cmd:        cmd,
+		watchers:   watchers,
+		monitored:  monitored,
+		done:       make(chan struct{}),
+		outputDone: make(chan struct{}),
+	}
+
+	var stderrWatchers, stdoutWatchers []Watcher
+	for _, w := range watchers {
+		if w.stderr {
+			stderrWatchers = append(stderrWatchers, w)
+		}
+		if w.stdout {
+			stdoutWatchers = append(stdoutWatchers, w)
+		}
+	}
+	p.stdout = &lineWriter{line: func(line string) { p.line(line, false, stdoutWatchers) }}
+	p.stderr = &lineWriter{line: func(line string) { p.line(line, true, stderrWatchers) }}
+
+	// The output is read from pipes, rather than by the command, so that its
+	// end is seen whether or not the command's Wait method is called.
+	var err error
+	if p.stdoutR, p.stdoutW, err = os.Pipe(); err != nil {
+		return nil, err
+	}
+	if p.stderrR, p.stderrW, err = os.Pipe(); err != nil {
+		p.stdoutR.Close()
+		p.stdoutW.Close()
+		return nil, err
+	}
+	cmd.Stdout = p.stdoutW
+	cmd.Stderr = p.stderrW
+	return p, nil
+}
+
+// readOutput closes the write ends of the output pipes, which the started
+// command has its own copies of, and reads the command's output until both
+// pipes are closed. Watchers are then told that the process has exited. If
+// the command could not be started, then started is false, and the output is
+// not read.
+func (p *Process) readOutput(started bool) {
+	p.stdoutW.Close()
+	p.stderrW.Close()
+	if !started {
+		p.stdoutR.Close()
+		p.stderrR.Close()
+		return
+	}
+
+	var wg sync.WaitGroup
+	read := func(r *os.File, buf *outputBuffer, w *lineWriter) {
+		defer wg.Done()
+		io.Copy(io.MultiWriter(buf, w), r)
+		r.Close()
+		w.flush()
+	}
+	wg.Add(2)
+	go read(p.stdoutR, &p.stdoutBuf, p.stdout)
+	go read(p.stderrR, &p.stderrBuf, p.stderr)
+	go func() {
+		wg.Wait()
+		close(p.outputDone)
+		for _, w := range p.watchers {
+			w.exited()
+		}
+	}()
+}
+
+// Cmd returns the command of the process.
+func (p *Process) Cmd() *exec.Cmd {
+	return p.cmd
+}
+
+// Args returns the command name and arguments of the process.
+func (p *Process) Args() CmdArgs {
+	return p.args
+}
+
+// PID returns the process ID.
+func (p *Process) PID() int {
+	return p.cmd.Process.Pid
+}
+
+// Running returns true if the process has not exited.
+func (p *Process) Running() bool {
+	return !p.exited()
+}
+
+// Wait waits for the process to exit, and returns the error that the
+// command's Wait method returned. Once the process has exited, all of its
+// output has been read.
+func (p *Process) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// ExitCode returns the exit code of the process, or -1 if the process is still
+// running or was terminated by a signal.
+func (p *Process) ExitCode() int {
+	if !p.exited() {
+		return -1
+	}
+	return p.cmd.ProcessState.ExitCode()
+}
+
+// Stdout returns the output that the process has written to stdout so far,
+// up to the last 1 MiB of it.
+func (p *Process) Stdout() []byte {
+	return p.stdoutBuf.Bytes()
+}
+
+// Stderr returns the output that the process has written to stderr so far,
+// up to the last 1 MiB of it.
+func (p *Process) Stderr() []byte {
+	return p.stderrBuf.Bytes()
+}
+
+// Signal sends a signal to the process. If the signal is one that normally
+// terminates a process, such as SIGINT, SIGTERM, SIGQUIT or SIGKILL, then the
+// process is expected to exit, so the test does not fail if it exits with an
+// error. Other signals, such as SIGHUP sent to reload a configuration, leave
+// the process monitored for exiting unexpectedly.
+func (p *Process) Signal(sig os.Signal) {
+	p.rnr.t.Helper()
+	if p.exited() {
+		return
+	}
+	if terminates(sig) {
+		p.stopping.Store(true)
+	}
+	err := p.cmd.Process.Signal(sig)
+	if err != nil && p.exited() {
+		return
+	}
+	require.NoError(p.rnr.t, err)
+}
+
+// Kill kills the process, and any other processes in its process group, and
+// waits for it to exit.
+func (p *Process) Kill() {
+	p.rnr.t.Helper()
+	if p.exited() {
+		return
+	}
+	p.stopping.Store(true)
+	err := signalGroup(p.cmd.Process, os.Kill)
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		require.NoError(p.rnr.t, err)
+	}
+	<-p.done
+}
+
+// StopStep is a step in stopping a process: a signal that is sent to the
+// process group, and how long to wait for the process to exit before the
+// next step.
+type StopStep struct {
+	Signal  os.Signal
+	Timeout time.Duration
+}
+
+// DefaultStopSteps returns the steps that Stop uses to stop a process, each
+// with the given timeout. On unix, these are SIGINT, SIGTERM and SIGKILL. On
+// Windows, which cannot send other signals, this is only SIGKILL.
+func DefaultStopSteps(timeout time.Duration) []StopStep {
+	return defaultStopSteps(timeout)
+}
+
+// StopResult reports which step of stopping a process terminated it.
+type StopResult struct {
+	// Step is the index of the step after which the process exited, or -1 if
+	// the process had already exited.
+	Step int
+	// Signal is the signal of the step, or nil if the process had already
+	// exited.
+	Signal os.Signal
+	// Elapsed is how long the process took to exit after the first signal
+	// was sent.
+	Elapsed time.Duration
+}
+
+func (r StopResult) String() string {
+	if r.Step == -1 {
+		return "already exited"
+	}
+	return fmt.Sprintf("exited after %s signal (step %d) in %s", r.Signal, r.Step+1, r.Elapsed)
+}
+
+// Stop stops the process, using the steps returned by DefaultStopSteps with
+// the given timeout. The signals are sent to the process group, so that any
+// processes that the process has started, such as the program run by "go
+// run", are also stopped. The test fails if the process exits with an error
+// after the first signal, unless that signal is SIGKILL, as it is on Windows.
+// If the process does not exit after the first signal, then the step that
+// stopped it is logged. Stop does nothing if the process has already exited.
+// Runner.Stop does not fail the test for an error after the first signal.
+func (p *Process) Stop(timeout time.Duration) {
+	p.rnr.t.Helper()
+	p.stop(timeout, true)
+}
+
+// stop stops the process as described by Stop. If checkErr is false, then an
+// error exit after the first signal is logged instead of failing the test.
+func (p *Process) stop(timeout time.Duration, checkErr bool) {
+	p.rnr.t.Helper()
+
+	res := p.StopSteps(DefaultStopSteps(timeout)...)
+	switch {
+	case res.Step == 0 && res.Signal != os.Kill && checkErr:
+		require.NoError(p.rnr.t, p.err, "%s exited with an error after %s signal", p.name, res.Signal)
+	case res.Step > 0 || (res.Step == 0 && p.err != nil):
+		p.rnr.t.Logf("stopped %s: %s: %v", p.name, res, p.err)
+	}
+}
+
+// StopSteps stops the process, by sending the signal of each step to the
+// process group in turn, until the process exits. It waits for the timeout of
+// each step before moving on to the next step. The test fails if the process
+// has not exited after the last step. StopSteps returns which step stopped the
+// process, and does not fail the test if the process exits with an error.
+func (p *Process) StopSteps(steps ...StopStep) StopResult {
+	p.rnr.t.Helper()
+	return p.stopSteps(p.done, steps)
+}
+
+// stopSteps sends the signal of each step to the process group in turn, until
+// done is closed.
+func (p *Process) stopSteps(done <-chan struct{}, steps []StopStep) StopResult {
+	p.rnr.t.Helper()
+
+	if closed(done) {
+		return StopResult{Step: -1}
+	}
+	p.stopping.Store(true)
+
+	start := time.Now()
+	for i, step := range steps {
+		if i > 0 {
+			p.rnr.t.Logf("%s did not exit within %s of %s signal, sending %s signal", p.name, steps[i-1].Timeout, steps[i-1].Signal, step.Signal)
+		}
+		err := signalGroup(p.cmd.Process, step.Signal)
+		if err != nil && !errors.Is(err, os.ErrProcessDone) && !closed(done) {
+			require.NoError(p.rnr.t, err)
+		}
+
+		timer := time.NewTimer(step.Timeout)
+		select {
+		case <-done:
+			timer.Stop()
+			return StopResult{
+				Step:    i,
+				Signal:  step.Signal,
+				Elapsed: time.Since(start),
+			}
+		case <-timer.C:
+		}
+	}
+	require.Fail(p.rnr.t, "process did not stop", "%s (pid %d) is still running after %d stop steps", p.name, p.PID(), len(steps))
+	return StopResult{}
+}
+
+// line handles a line of output.
+func (p *Process) line(line string, stderr bool, watchers []Watcher) {
+	if p.rnr.verbose {
+		p.rnr.logf("%s: %s", p.name, line)
+	}
+	p.tail.add(line)
+
+	forbidden := func() {
+		p.rnr.errorf("%s: output must not match: %s", p.name, line)
+	}
+	for _, w := range watchers {
+		w.match(line, stderr, forbidden)
+	}
+
+	if p.args.allowFailure {
+		return
+	}
+	for _, prefix := range failurePrefixes {
+		if strings.HasPrefix(line, prefix) {
+			p.fail(line)
+			break
+		}
+	}
+}
+
+// fail fails the test because of the output line, after waiting for the rest
+// of the failure report to be output.
+func (p *Process) fail(line string) {
+	p.failMu.Lock()
+	defer p.failMu.Unlock()
+	if p.failure != "" {
+		return
+	}
+	p.failure = line
+	time.AfterFunc(failureDelay, p.reportFailure)
+}
+
+// reportFailure fails the test with the tail of the output, if there has been
+// a failure that has not yet been reported.
+func (p *Process) reportFailure() {
+	p.failMu.Lock()
+	defer p.failMu.Unlock()
+	if p.failure == "" || p.reported {
+		return
+	}
+	p.reported = true
+	p.rnr.errorf("%s failed: %s\nlast %d lines of output:\n%s", p.name, p.failure, tailLines, p.tail.String())
+}
+
+// startMonitor starts waiting for the process to exit, if it is not already
+// being waited for.
+func (p *Process) startMonitor() {
+	if p.waiting.CompareAndSwap(false, true) {
+		go p.monitor()
+	}
+}
+
+// monitor waits for the process to exit, and for its output to be read, and
+// fails the test if it is monitored and exited unexpectedly.
+func (p *Process) monitor() {
+	err := p.cmd.Wait()
+
+	// Do not wait forever for output from any processes that the process
+	// started and left running.
+	timer := time.NewTimer(waitDelay)
+	select {
+	case <-p.outputDone:
+		timer.Stop()
+	case <-timer.C:
+		p.stdoutR.Close()
+		p.stderrR.Close()
+		<-p.outputDone
+	}
+	p.err = err
+	close(p.done)
+
+	// An exit error is expected when the process is stopped or its context
+	// is canceled, or if its failure is allowed.
+	if err != nil && p.monitored && !p.args.allowFailure && !p.stopping.Load() && p.ctx.Err() == nil {
+		p.failMu.Lock()
+		if p.failure == "" {
+			p.failure = fmt.Sprintf("exited unexpectedly: %s", err)
+		}
+		p.failMu.Unlock()
+	}
+	p.reportFailure()
+}
+
+// cleanup stops the process if it is still running at the end of the test,
+// and kills any other processes left running in its process group, such as
+// the program run by "go run". Both are reported in the test log.
+func (p *Process) cleanup() {
+	waiting := p.waiting.Load()
+	switch {
+	case waiting:
+		if p.Running() {
+			p.rnr.t.Logf("stopping %s (pid %d), which is still running at the end of the test", p.name, p.PID())
+			res := p.stopSteps(p.done, DefaultStopSteps(cleanupTimeout))
+			p.rnr.t.Logf("stopped %s: %s", p.name, res)
+		}
+	case !closed(p.outputDone):
+		// The command was started by Runner.Start, and waiting for it is left
+		// to the caller, who may be calling its Wait method, so it must not
+		// be waited for here. It is instead taken to have exited once its
+		// output is closed.
+		p.rnr.t.Logf("stopping %s (pid %d), which is still running at the end of the test", p.name, p.PID())
+		res := p.stopSteps(p.outputDone, DefaultStopSteps(cleanupTimeout))
+		p.rnr.t.Logf("stopped %s: %s", p.name, res)
+	}
+	// A command that has not been waited for is still in its process group,
+	// so killing the group is only reported if the command has been waited
+	// for.
+	if killGroup(p.cmd.Process) && waiting {
+		p.rnr.t.Logf("killed processes left running by %s (pid %d)", p.name, p.PID())
+	}
+}
+
+// exited returns true if the process has exited.
+func (p *Process) exited() bool {
+	return closed(p.done)
+}
+
+// closed returns true if the channel is closed.
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// lineWriter calls a function for each line written to it. A partial line
+// at the end of output is passed to the function when flushed.
+type lineWriter struct {
+	line func(string)
+	buf  []byte
+}
+
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i == -1 {
+			break
+		}
+		w.line(string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+func (w *lineWriter) flush() {
+	if len(w.buf) != 0 {
+		w.line(string(w.buf))
+		w.buf = nil
+	}
+}
+
+// tailBuffer keeps the last tailLines lines of output.
+type tailBuffer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (b *tailBuffer) add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.lines) == tailLines {
+		b.lines = append(b.lines[:0], b.lines[1:]...)
+	}
+	b.lines = append(b.lines, line)
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Join(b.lines, "\n")
+}
+
+// outputBuffer keeps the last outputLimit bytes of output.
+type outputBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *outputBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, data...)
+	// Trim the buffer once it holds twice the limit, rather than on every
+	// write.
+	if len(b.buf) > 2*outputLimit {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-outputLimit:]...)
+	}
+	return len(data), nil
+}
+
+// Bytes returns a copy of the last outputLimit bytes of output.
+func (b *outputBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf[max(len(b.buf)-outputLimit, 0):])
+}
@@ -0,0 +1,32 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup does nothing, as process groups are only supported on unix.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends a signal to the process.
+func signalGroup(proc *os.Process, sig os.Signal) error {
+	return proc.Signal(sig)
+}
+
+// killGroup returns false, as process groups are only supported on unix.
+func killGroup(proc *os.Process) bool {
+	return false
+}
+
+// terminates returns true if the signal is one that is sent to end a process.
+func terminates(sig os.Signal) bool {
+	return sig == os.Interrupt || sig == os.Kill
+}
+
+func defaultStopSteps(timeout time.Duration) []StopStep {
+	// Windows can't send SIGINT.
+	return []StopStep{{Signal: os.Kill, Timeout: timeout}}
+}
@@ -0,0 +1,389 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-test/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+var failSrc = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt)
+
+	fmt.Println("starting")
+	switch os.Args[1] {
+	case "panic":
+		panic("something went wrong")
+	case "exit":
+		fmt.Fprintln(os.Stderr, "cannot continue")
+		os.Exit(3)
+	case "race":
+		fmt.Fprintln(os.Stderr, "==================")
+		fmt.Fprintln(os.Stderr, "WARNING: DATA RACE")
+		fmt.Fprintln(os.Stderr, "Write at 0x00c000012345 by goroutine 7:")
+	}
+	<-shutdown
+	if os.Args[1] == "interrupt" {
+		fmt.Fprintln(os.Stderr, "failed to shut down")
+		os.Exit(1)
+	}
+}
+`
+
+func TestProcessExited(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, repeatSrc)
+	r := cmd.NewRunner(t, dir)
+
+	w := cmd.NewWatcher("never output")
+	p := r.StartProcess(context.Background(), cmd.Args(bin, "tick", "3"), w)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.ErrorIs(t, w.Wait(ctx), cmd.ErrProcessExited)
+	require.ErrorIs(t, w.WaitCount(ctx, 1), cmd.ErrProcessExited)
+	require.NoError(t, p.Wait())
+
+	// Stopping a command that has exited does nothing.
+	p.Stop(time.Second)
+}
+
+func TestProcessStopped(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, failSrc)
+	r := cmd.NewRunner(t, dir)
+
+	w := cmd.NewWatcher("starting")
+	c := r.Start(context.Background(), cmd.Args(bin, "wait"), w)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, w.Wait(ctx))
+	r.Stop(c, 5*time.Second)
+
+	// Canceling the context kills the command, which is not a failure.
+	cmdCtx, cmdCancel := context.WithCancel(context.Background())
+	w = cmd.NewWatcher("starting")
+	c = r.Start(cmdCtx, cmd.Args(bin, "wait"), w)
+	require.NoError(t, w.Wait(ctx))
+	cmdCancel()
+	require.Error(t, r.Wait(c))
+}
+
+func TestProcessOutputLimit(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, repeatSrc)
+	r := cmd.NewRunner(t, dir)
+
+	p := r.StartProcess(context.Background(), cmd.Args(bin, "tick", "300000"))
+	require.NoError(t, p.Wait())
+	out := p.Stdout()
+	require.Len(t, out, 1<<20)
+	require.True(t, bytes.HasSuffix(out, []byte("\ntick 300000\n")))
+	require.Empty(t, p.Stderr())
+}
+
+func TestFailureAllowed(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, failSrc)
+	r := cmd.NewRunner(t, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	w := cmd.NewWatcher("never output")
+	c := r.Start(ctx, cmd.Args(bin, "panic").WithFailureAllowed(), w)
+	require.ErrorIs(t, w.Wait(ctx), cmd.ErrProcessExited)
+	require.Error(t, c.Wait())
+
+	p := r.StartProcess(ctx, cmd.Args(bin, "exit").WithFailureAllowed())
+	require.Error(t, p.Wait())
+	require.Equal(t, 3, p.ExitCode())
+
+	// Give any failure time to be reported before the test ends.
+	time.Sleep(time.Second)
+}
+
+func TestProcessFailure(t *testing.T) {
+	if mode := os.Getenv("TEST_PROCESS_FAILURE"); mode != "" {
+		dir := t.TempDir()
+		bin := buildSrc(t, dir, failSrc)
+		r := cmd.NewRunner(t, dir)
+		p := r.StartProcess(context.Background(), cmd.Args(bin, mode))
+		if mode == "hangup" {
+			// The process is not expected to exit on SIGHUP, which is not
+			// handled, so it crashes.
+			p.Signal(syscall.SIGHUP)
+		}
+		if mode == "race" || mode == "interrupt" {
+			time.Sleep(time.Second)
+			p.Stop(5 * time.Second)
+			return
+		}
+		p.Wait()
+		return
+	}
+
+	for mode, want := range map[string][]string{
+		"panic":     {"failed: panic: something went wrong", "goroutine 1 [running]:"},
+		"exit":      {"exited unexpectedly: exit status 3", "cannot continue", "starting"},
+		"race":      {"failed: WARNING: DATA RACE", "Write at 0x00c000012345 by goroutine 7:"},
+		"hangup":    {"exited unexpectedly: signal: hangup"},
+		"interrupt": {"exited with an error after interrupt signal", "exit status 1"},
+	} {
+		t.Run(mode, func(t *testing.T) {
+			if (mode == "hangup" || mode == "interrupt") && runtime.GOOS == "windows" {
+				t.Skip("windows cannot send SIGHUP or SIGINT")
+			}
+			r := cmd.NewRunner(t, t.TempDir())
+			r.Env = append(r.Env, "TEST_PROCESS_FAILURE="+mode)
+			res := r.RunWithResult(context.Background(), os.Args[0], "-test.run=^TestProcessFailure$", "-test.v")
+			res.ExpectFailure()
+			for _, s := range want {
+				res.ExpectStdoutContains(s)
+			}
+		})
+	}
+}
+
+var envSrc = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt)
+
+	wd, _ := os.Getwd()
+	fmt.Println("value:", os.Getenv("PROCESS_VALUE"))
+	fmt.Println("dir:", wd)
+	fmt.Fprintln(os.Stderr, "ready")
+	<-shutdown
+	fmt.Println("stopped")
+}
+`
+
+func TestRunnerStopError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows cannot send SIGINT")
+	}
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, failSrc)
+	r := cmd.NewRunner(t, dir)
+
+	// Runner.Stop logs, rather than fails the test for, an error exit after
+	// SIGINT.
+	w := cmd.NewWatcher("starting")
+	c := r.Start(context.Background(), cmd.Args(bin, "interrupt"), w)
+	require.NoError(t, w.Wait(context.Background()))
+	r.Stop(c, 5*time.Second)
+	require.Equal(t, 1, r.Process(c).ExitCode())
+}
+
+func TestStartCleanup(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, failSrc)
+
+	waited := make(chan error, 1)
+	t.Run("start", func(t *testing.T) {
+		r := cmd.NewRunner(t, dir)
+		w := cmd.NewWatcher("starting")
+		c := r.Start(context.Background(), cmd.Args(bin, "wait"), w)
+		require.NoError(t, w.Wait(context.Background()))
+		go func() { waited <- c.Wait() }()
+	})
+	// The cleanup stops the command without waiting for it, which would race
+	// with the caller's Wait.
+	require.NoError(t, <-waited)
+}
+
+func TestProcess(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, envSrc)
+	r := cmd.NewRunner(t, dir)
+	workDir := t.TempDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	w := cmd.NewStdoutWatcher("dir:")
+	args := cmd.Args(bin).WithEnv("PROCESS_VALUE=first", "PROCESS_VALUE=second").WithDir(workDir)
+	p := r.StartProcess(context.Background(), args, w)
+	require.Equal(t, p, r.Process(p.Cmd()))
+	require.Equal(t, args, p.Args())
+	require.Positive(t, p.PID())
+	require.NoError(t, w.Wait(ctx))
+	require.True(t, p.Running())
+	require.Equal(t, -1, p.ExitCode())
+
+	require.Contains(t, string(p.Stdout()), "value: second\n")
+	realWorkDir, err := filepath.EvalSymlinks(workDir)
+	require.NoError(t, err)
+	require.Contains(t, string(p.Stdout()), "dir: "+realWorkDir+"\n")
+
+	p.Stop(5 * time.Second)
+	require.False(t, p.Running())
+	require.Equal(t, 0, p.ExitCode())
+	require.NoError(t, p.Wait())
+	require.Contains(t, string(p.Stdout()), "stopped\n")
+	require.Equal(t, "ready\n", string(p.Stderr()))
+
+	w = cmd.NewStdoutWatcher("dir:")
+	c := r.Start(context.Background(), cmd.Args(bin), w)
+	require.NoError(t, w.Wait(ctx))
+	p = r.Process(c)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.Contains(t, string(p.Stdout()), "dir: "+wd+"\n")
+	p.Kill()
+	require.False(t, p.Running())
+	require.Equal(t, -1, p.ExitCode())
+	require.Error(t, p.Wait())
+}
+
+var spawnSrc = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	if os.Args[1] == "child" {
+		time.Sleep(time.Minute)
+		return
+	}
+	child := exec.Command(os.Args[0], "child")
+	if err := child.Start(); err != nil {
+		panic(err)
+	}
+	fmt.Println("child", child.Process.Pid)
+	if os.Args[1] == "orphan" {
+		return
+	}
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt)
+	<-shutdown
+}
+`
+
+func TestCleanup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checks processes using /proc")
+	}
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, spawnSrc)
+
+	for _, mode := range []string{"wait", "orphan"} {
+		var p *cmd.Process
+		var childPID string
+		t.Run(mode, func(t *testing.T) {
+			r := cmd.NewRunner(t, dir)
+			w := cmd.NewRegexpWatcher(regexp.MustCompile(`^child (\d+)$`))
+			p = r.StartProcess(context.Background(), cmd.Args(bin, mode), w)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			m, err := w.WaitMatch(ctx)
+			require.NoError(t, err)
+			childPID = m.Submatch(1)
+			require.True(t, running(childPID))
+			if mode == "orphan" {
+				require.NoError(t, p.Wait())
+			}
+		})
+		require.False(t, p.Running())
+		require.Eventually(t, func() bool { return !running(childPID) }, time.Second, 10*time.Millisecond,
+			"child process of %s is still running", mode)
+	}
+}
+
+// running returns true if the process with the given ID is running, and is not
+// a zombie.
+func running(pid string) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", pid, "stat"))
+	if err != nil {
+		return false
+	}
+	_, state, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(state, "Z")
+}
+
+var ignoreSrc = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	signal.Ignore(os.Interrupt)
+	if os.Args[1] == "term" {
+		signal.Ignore(syscall.SIGTERM)
+	}
+	fmt.Println("ready")
+	time.Sleep(time.Minute)
+}
+`
+
+func TestStopSteps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows cannot send SIGINT or SIGTERM")
+	}
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, ignoreSrc)
+	r := cmd.NewRunner(t, dir)
+
+	steps := cmd.DefaultStopSteps(200 * time.Millisecond)
+	require.Len(t, steps, 3)
+
+	for i, mode := range []string{"int", "term"} {
+		w := cmd.NewWatcher("ready")
+		p := r.StartProcess(context.Background(), cmd.Args(bin, mode), w)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		require.NoError(t, w.Wait(ctx))
+		cancel()
+
+		res := p.StopSteps(steps...)
+		require.Equal(t, i+1, res.Step, "%s stopped by wrong step", mode)
+		require.Equal(t, steps[i+1].Signal, res.Signal)
+		require.GreaterOrEqual(t, res.Elapsed, time.Duration(i+1)*200*time.Millisecond)
+		require.Contains(t, res.String(), steps[i+1].Signal.String())
+		require.False(t, p.Running())
+
+		res = p.StopSteps(steps...)
+		require.Equal(t, -1, res.Step)
+		require.Nil(t, res.Signal)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build unix
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts the command in its own process group, so that the
+// command and any processes it starts can be signaled together. The process
+// group is killed if the command's context is canceled.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return signalGroup(cmd.Process, os.Kill)
+	}
+}
+
+// signalGroup sends a signal to the process group of the process.
+func signalGroup(proc *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return proc.Signal(sig)
+	}
+	err := syscall.Kill(-proc.Pid, s)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// killGroup kills any processes remaining in the process group of the
+// process, and returns true if there were any.
+func killGroup(proc *os.Process) bool {
+	if syscall.Kill(-proc.Pid, 0) != nil {
+		return false
+	}
+	return syscall.Kill(-proc.Pid, syscall.SIGKILL) == nil
+}
+
+// terminates returns true if the signal is one that is sent to end a process.
+func terminates(sig os.Signal) bool {
+	switch sig {
+	case os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGQUIT:
+		return true
+	}
+	return false
+}
+
+func defaultStopSteps(timeout time.Duration) []StopStep {
+	return []StopStep{
+		{Signal: os.Interrupt, Timeout: timeout},
+		{Signal: syscall.SIGTERM, Timeout: timeout},
+		{Signal: os.Kill, Timeout: timeout},
+	}
+}
@@ -1,16 +1,17 @@
 package cmd
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -31,6 +32,14 @@ const (
 type Runner struct {
 	t       *testing.T
 	verbose bool
+	cover   bool
+
+	// mu protects done, which is set when the test has finished and output
+	// from commands can no longer be reported, and procs, which are the
+	// processes started by Start.
+	mu    sync.Mutex
+	done  bool
+	procs map[*exec.Cmd]*Process
 
 	Dir string
 	Env []string
@@ -85,29 +94,212 @@ func NewRunner(t *testing.T, dir string) *Runner {
 		rnr.Env = append(rnr.Env, fmt.Sprintf("%s=%s", name, out))
 	}
 
+	t.Cleanup(func() {
+		rnr.mu.Lock()
+		rnr.done = true
+		rnr.mu.Unlock()
+	})
+
 	return &rnr
 }
 
-// Run runs a command and returns its output. This is useful for executing
-// synchronous commands within the temporary environment.
+// logf logs to the test from a goroutine that may outlive the test.
+func (rnr *Runner) logf(format string, args ...any) {
+	rnr.t.Helper()
+	rnr.mu.Lock()
+	defer rnr.mu.Unlock()
+	if !rnr.done {
+		rnr.t.Logf(format, args...)
+	}
+}
+
+// errorf fails the test from a goroutine that may outlive the test.
+func (rnr *Runner) errorf(format string, args ...any) {
+	rnr.t.Helper()
+	rnr.mu.Lock()
+	defer rnr.mu.Unlock()
+	if !rnr.done {
+		rnr.t.Errorf(format, args...)
+	}
+}
+
+// Run runs a command and returns its combined stdout and stderr output, in the
+// order it was written. This is useful for executing synchronous commands
+// within the temporary environment. The test fails if the command cannot be
+// run or exits with a non-zero exit code. Use RunWithResult to check commands
+// that are expected to fail.
 func (rnr *Runner) Run(ctx context.Context, name string, args ...string) []byte {
 	rnr.t.Helper()
 
+	res := rnr.run(ctx, Args(name, args...), false)
+	require.NoError(rnr.t, res.Err, string(res.Output))
+	return res.Output
+}
+
+// RunWithResult runs a command and returns the result, without failing the
+// test if the command cannot be run or exits with a non-zero exit code. The
+// result can then be checked using its Expect methods.
+func (rnr *Runner) RunWithResult(ctx context.Context, name string, args ...string) *RunResult {
+	rnr.t.Helper()
+	return rnr.RunCmd(ctx, Args(name, args...))
+}
+
+// RunCmd is the same as RunWithResult, but takes the command as CmdArgs, which
+// can also supply the command's stdin.
+func (rnr *Runner) RunCmd(ctx context.Context, args CmdArgs) *RunResult {
+	rnr.t.Helper()
+	return rnr.run(ctx, args, true)
+}
+
+// run runs a command. If separate is false, then stdout and stderr are written
+// to the same pipe, so that the combined output is in the order it was
+// written, and only the combined output is kept.
+func (rnr *Runner) run(ctx context.Context, args CmdArgs, separate bool) *RunResult {
+	rnr.t.Helper()
+
 	if rnr.verbose {
-		rnr.t.Logf("run: %s %s", name, strings.Join(args, " "))
+		rnr.t.Logf("run: %s", args.String())
+	}
+
+	res := &RunResult{
+		t:    rnr.t,
+		Args: args,
+	}
+	var stdout, stderr bytes.Buffer
+	combined := &lockedBuffer{}
+	cmd := rnr.command(ctx, args)
+	if separate {
+		cmd.Stdout = io.MultiWriter(&stdout, combined)
+		cmd.Stderr = io.MultiWriter(&stderr, combined)
+	} else {
+		cmd.Stdout = combined
+		cmd.Stderr = combined
+	}
+
+	start := time.Now()
+	res.Err = cmd.Start()
+	if res.Err == nil {
+		args.closeStdinPipe()
+		res.Err = cmd.Wait()
 	}
+	res.Duration = time.Since(start)
+	res.Stdout = stdout.Bytes()
+	res.Stderr = stderr.Bytes()
+	res.Output = combined.Bytes()
 
-	cmd := exec.CommandContext(ctx, name, args...)
-	cmd.Env = rnr.Env
-	out, err := cmd.CombinedOutput()
-	require.NoError(rnr.t, err, string(out))
-	return out
+	res.ExitCode = -1
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+		if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			res.Signal = ws.Signal()
+		}
+	}
+	return res
 }
 
-// CmdArgs contains a command name and any arguments.
+// RunResult is the result of running a command with RunWithResult.
+type RunResult struct {
+	t *testing.T
+
+	// Args is the command that was run.
+	Args CmdArgs
+	// ExitCode is the exit code of the command, or -1 if the command could
+	// not be run or was terminated by a signal.
+	ExitCode int
+	// Signal is the signal that terminated the command, or nil.
+	Signal os.Signal
+	// Stdout is the output written to stdout.
+	Stdout []byte
+	// Stderr is the output written to stderr.
+	Stderr []byte
+	// Output is the output written to stdout and stderr. The output written
+	// to each is in the order it was written, but as stdout and stderr are
+	// read separately, output written to one may be out of order with output
+	// written to the other. Run returns the combined output in order.
+	Output []byte
+	// Duration is how long the command ran for.
+	Duration time.Duration
+	// Err is the error from running the command. It is an *exec.ExitError if
+	// the command exited with a non-zero exit code.
+	Err error
+}
+
+// ExpectExit fails the test if the command did not exit with the given exit
+// code.
+func (r *RunResult) ExpectExit(code int) *RunResult {
+	r.t.Helper()
+	if r.ExitCode != code {
+		r.t.Fatalf("expected %q to exit with code %d, got %d (%v)\n%s", r.Args, code, r.ExitCode, r.Err, r.Output)
+	}
+	return r
+}
+
+// ExpectSuccess fails the test if the command did not exit with exit code 0.
+func (r *RunResult) ExpectSuccess() *RunResult {
+	r.t.Helper()
+	return r.ExpectExit(0)
+}
+
+// ExpectFailure fails the test if the command exited with exit code 0.
+func (r *RunResult) ExpectFailure() *RunResult {
+	r.t.Helper()
+	if r.Err == nil {
+		r.t.Fatalf("expected %q to fail\n%s", r.Args, r.Output)
+	}
+	return r
+}
+
+// ExpectStdoutContains fails the test if stdout does not contain s.
+func (r *RunResult) ExpectStdoutContains(s string) *RunResult {
+	r.t.Helper()
+	if !bytes.Contains(r.Stdout, []byte(s)) {
+		r.t.Fatalf("expected stdout of %q to contain %q, got:\n%s", r.Args, s, r.Stdout)
+	}
+	return r
+}
+
+// ExpectStderrContains fails the test if stderr does not contain s.
+func (r *RunResult) ExpectStderrContains(s string) *RunResult {
+	r.t.Helper()
+	if !bytes.Contains(r.Stderr, []byte(s)) {
+		r.t.Fatalf("expected stderr of %q to contain %q, got:\n%s", r.Args, s, r.Stderr)
+	}
+	return r
+}
+
+// lockedBuffer is a buffer that stdout and stderr can be written to
+// concurrently.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// CmdArgs contains a command name and any arguments, and optionally the
+// command's stdin, environment and working directory, and how to tell when the
+// started command is ready.
 type CmdArgs struct {
 	name string
 	args []string
+
+	ready     []Probe
+	env       []string
+	dir       string
+	stdin     io.Reader
+	stdinPipe *StdinPipe
+
+	allowFailure bool
 }
 
 // Args creates a CmdArgs instance with the given command name and args. This
@@ -123,87 +315,235 @@ func (a CmdArgs) String() string {
 	return a.name + " " + strings.Join(a.args, " ")
 }
 
+// WithReady returns a copy of the CmdArgs that makes Start wait for the started
+// command to be ready, as checked by the probes, and fail the test if it is
+// not. For example, to wait for a daemon's API to be available:
+//
+//	args := cmd.Args("ipfs", "daemon").WithReady(cmd.HTTPProbe(apiURL+"/api/v0/id", 405))
+func (a CmdArgs) WithReady(probes ...Probe) CmdArgs {
+	a.ready = append(slices.Clip(a.ready), probes...)
+	return a
+}
+
+// WithEnv returns a copy of the CmdArgs that runs the command with the given
+// environment variables, in the form "key=value", in addition to those of the
+// Runner. A variable that is also set in the Runner's environment is
+// overridden.
+func (a CmdArgs) WithEnv(env ...string) CmdArgs {
+	a.env = append(slices.Clip(a.env), env...)
+	return a
+}
+
+// WithDir returns a copy of the CmdArgs that runs the command in the given
+// working directory. If no directory is given, the command runs in the
+// current directory.
+func (a CmdArgs) WithDir(dir string) CmdArgs {
+	a.dir = dir
+	return a
+}
+
+// WithStdin returns a copy of the CmdArgs that reads the command's stdin from
+// r. The command reads EOF when r returns EOF. If no stdin is given, the
+// command reads from the null device.
+func (a CmdArgs) WithStdin(r io.Reader) CmdArgs {
+	a.stdin = r
+	a.stdinPipe = nil
+	return a
+}
+
+// WithStdinBytes returns a copy of the CmdArgs that reads the command's stdin
+// from data.
+func (a CmdArgs) WithStdinBytes(data []byte) CmdArgs {
+	return a.WithStdin(bytes.NewReader(data))
+}
+
+// WithStdinPipe returns a copy of the CmdArgs that reads the command's stdin
+// from the pipe. This allows writing to the stdin of a started command, and
+// closing it when the command should read EOF.
+func (a CmdArgs) WithStdinPipe(p *StdinPipe) CmdArgs {
+	a.stdin = p.r
+	a.stdinPipe = p
+	return a
+}
+
+// WithFailureAllowed returns a copy of the CmdArgs for a command that is
+// expected to crash. The test does not fail if the started command outputs a
+// Go panic or race detector report, or if a command started by StartProcess
+// exits unexpectedly.
+func (a CmdArgs) WithFailureAllowed() CmdArgs {
+	a.allowFailure = true
+	return a
+}
+
+// closeStdinPipe closes the read end of the stdin pipe, which the started
+// command has its own copy of.
+func (a CmdArgs) closeStdinPipe() {
+	if a.stdinPipe != nil {
+		a.stdinPipe.r.Close()
+	}
+}
+
+// StdinPipe is a pipe that is written to the stdin of a command. Supply it to
+// a command using CmdArgs.WithStdinPipe.
+type StdinPipe struct {
+	r *os.File
+	w *os.File
+}
+
+// StdinPipe creates a new StdinPipe. The pipe is closed when the test
+// finishes, if it has not already been closed.
+func (rnr *Runner) StdinPipe() *StdinPipe {
+	rnr.t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(rnr.t, err)
+	rnr.t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+	return &StdinPipe{
+		r: r,
+		w: w,
+	}
+}
+
+// Write writes data to the command's stdin. It blocks if the command is not
+// reading its stdin and the pipe buffer is full.
+func (p *StdinPipe) Write(data []byte) (int, error) {
+	return p.w.Write(data)
+}
+
+// Close closes the pipe, so that the command reads EOF from its stdin.
+func (p *StdinPipe) Close() error {
+	return p.w.Close()
+}
+
 // Start starts and returns the command. This is useful for executing
 // asynchronous commands within the temporary environment. If any watchers are
 // supplied, the command's stdout is scanned to look for any matches and signal
-// the corresponding watchers.
+// the corresponding watchers. If a watcher made by Watcher.MustNotMatch
+// matches, the test fails. The command's stdin is supplied by args, as set
+// by CmdArgs.WithStdin or CmdArgs.WithStdinPipe.
+//
+// The test fails, showing the last lines of output, if the command outputs a
+// Go panic or race detector report, unless the args are made by
+// CmdArgs.WithFailureAllowed. When the command, and any processes that it
+// started, have exited and closed their output, waiting on its watchers
+// returns ErrProcessExited, and waiting for it to be ready fails without
+// waiting for the probes to time out.
+//
+// The caller may wait for the command to exit using its Wait method. Passing
+// the command to Runner.Wait, Runner.Stop or Runner.Process instead leaves
+// waiting for the command to the Runner, after which the command's Wait method
+// must not be called. Use StartProcess to also have the command monitored
+// until it exits.
+//
+// If the args have probes, set by CmdArgs.WithReady, then Start waits for the
+// command to be ready before returning, and the test fails if it is not.
+//
+// On unix, the command is started in its own process group. When the test
+// finishes, the command is stopped if it is still running, and any processes
+// that it started and left running are killed. The command is stopped using
+// the steps returned by DefaultStopSteps, each with a timeout of 5 seconds, so
+// the test's cleanup can block for up to 15 seconds for a command that does
+// not exit.
 func (rnr *Runner) Start(ctx context.Context, args CmdArgs, watchers ...Watcher) *exec.Cmd {
 	rnr.t.Helper()
+	return rnr.start(ctx, args, watchers, false).Cmd()
+}
 
-	name := filepath.Base(args.name)
-	if rnr.verbose {
-		rnr.t.Logf("run: %s", args.String())
-	}
+// StartProcess starts the command, as described by Start, and returns its
+// Process, which monitors the command until it exits. The test fails if the
+// command exits with an error before it is stopped or its context is
+// canceled, unless the args are made by CmdArgs.WithFailureAllowed. As the
+// Process waits for the command, use Process.Wait instead of the command's
+// Wait method.
+func (rnr *Runner) StartProcess(ctx context.Context, args CmdArgs, watchers ...Watcher) *Process {
+	rnr.t.Helper()
+	return rnr.start(ctx, args, watchers, true)
+}
 
-	cmd := exec.CommandContext(ctx, args.name, args.args...)
-	cmd.Env = rnr.Env
+// start starts the command. If monitor is true, then the command is waited
+// for, and so monitored, from when it starts, rather than when the command is
+// first passed to Runner.Process.
+func (rnr *Runner) start(ctx context.Context, args CmdArgs, watchers []Watcher, monitor bool) *Process {
+	rnr.t.Helper()
 
-	var stderrWatchers, stdoutWatchers []Watcher
-	for _, w := range watchers {
-		if w.stderr {
-			stderrWatchers = append(stderrWatchers, w)
-		}
-		if w.stdout {
-			stdoutWatchers = append(stdoutWatchers, w)
-		}
+	if rnr.verbose {
+		rnr.t.Logf("run: %s", args.String())
 	}
 
-	watch := func(stream io.ReadCloser, watchers []Watcher) {
-		scanner := bufio.NewScanner(stream)
-		for scanner.Scan() {
-			line := strings.ToLower(scanner.Text())
+	cmd := rnr.command(ctx, args)
+	// Do not wait forever for the command to exit after its context is
+	// canceled and it is killed.
+	cmd.WaitDelay = waitDelay
+	setProcessGroup(cmd)
+	p, err := newProcess(rnr, ctx, args, cmd, watchers, monitor)
+	require.NoError(rnr.t, err)
 
-			if rnr.verbose {
-				rnr.t.Logf("%s: %s", name, line)
-			}
+	err = cmd.Start()
+	p.readOutput(err == nil)
+	require.NoError(rnr.t, err)
+	args.closeStdinPipe()
+	rnr.t.Cleanup(p.cleanup)
 
-			for _, w := range watchers {
-				if strings.Contains(line, strings.ToLower(w.match)) {
-					w.signal <- struct{}{}
-				}
-			}
-		}
+	rnr.mu.Lock()
+	if rnr.procs == nil {
+		rnr.procs = make(map[*exec.Cmd]*Process)
 	}
+	rnr.procs[cmd] = p
+	rnr.mu.Unlock()
 
-	if rnr.verbose || len(stderrWatchers) != 0 {
-		stderr, err := cmd.StderrPipe()
-		require.NoError(rnr.t, err)
-		go watch(stderr, stderrWatchers)
+	if monitor {
+		p.startMonitor()
 	}
-	if rnr.verbose || len(stdoutWatchers) != 0 {
-		stdout, err := cmd.StdoutPipe()
+
+	if len(args.ready) != 0 {
+		err = p.WaitReady(ctx, args.ready...)
 		require.NoError(rnr.t, err)
-		go watch(stdout, stdoutWatchers)
 	}
+	return p
+}
 
-	err := cmd.Start()
-	require.NoError(rnr.t, err)
+// command creates the command for args, in the runner's environment.
+func (rnr *Runner) command(ctx context.Context, args CmdArgs) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, args.name, args.args...)
+	cmd.Env = append(slices.Clip(rnr.Env), args.env...)
+	cmd.Dir = args.dir
+	cmd.Stdin = args.stdin
 	return cmd
 }
 
-// Stop stops a command. It sends SIGINT, and if that does not work, SIGKILL.
+// Wait waits for a command started by Start to exit, and returns the error
+// that the command's Wait method returned.
+func (rnr *Runner) Wait(cmd *exec.Cmd) error {
+	rnr.t.Helper()
+	return rnr.Process(cmd).Wait()
+}
+
+// Stop stops a command, as described by Process.Stop. It sends SIGINT, then
+// SIGTERM, and then SIGKILL, to the command's process group, until the
+// command exits. Unlike Process.Stop, an error exit after SIGINT is logged
+// rather than failing the test, as SIGINT is sent to every process in the
+// group and a command can exit with an error because of it. For example, the
+// go command exits with an error when "go run" is interrupted, even if the
+// program that it runs exits successfully.
 func (rnr *Runner) Stop(cmd *exec.Cmd, timeout time.Duration) {
-	sig := os.Interrupt
-	if runtime.GOOS == "windows" {
-		// Windows can't send SIGINT.
-		sig = os.Kill
-	}
-	err := cmd.Process.Signal(sig)
-	require.NoError(rnr.t, err)
+	rnr.t.Helper()
+	rnr.Process(cmd).stop(timeout, false)
+}
 
-	waitErr := make(chan error, 1)
-	go func() { waitErr <- cmd.Wait() }()
+// Process returns the Process of a command started by Start. The Process then
+// waits for the command, so the command's Wait method must not be called,
+// but the command is not monitored as described by StartProcess. The test
+// fails if the command was not started by Start.
+func (rnr *Runner) Process(cmd *exec.Cmd) *Process {
+	rnr.t.Helper()
 
-	timer := time.NewTimer(timeout)
-	select {
-	case <-timer.C:
-		rnr.t.Logf("killing command after %s: %s", timeout, cmd)
-		err = cmd.Process.Kill()
-		require.NoError(rnr.t, err)
-	case err = <-waitErr:
-		if runtime.GOOS != "windows" {
-			require.NoError(rnr.t, err)
-		}
-		timer.Stop()
-	}
+	rnr.mu.Lock()
+	p, ok := rnr.procs[cmd]
+	rnr.mu.Unlock()
+	require.True(rnr.t, ok, "command was not started by Runner.Start: %s", cmd)
+	p.startMonitor()
+	return p
 }
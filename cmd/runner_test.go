@@ -3,7 +3,10 @@ package cmd_test
 import (
 	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,6 +44,134 @@ func TestStart(t *testing.T) {
 	r.Stop(c, time.Second)
 }
 
+func TestRunWithResult(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, exitSrc)
+	r := cmd.NewRunner(t, dir)
+	ctx := context.Background()
+
+	res := r.RunWithResult(ctx, bin, "3")
+	res.ExpectExit(3).ExpectFailure()
+	res.ExpectStdoutContains("exiting on stdout")
+	res.ExpectStderrContains("exiting with 3 on stderr")
+	require.NotContains(t, string(res.Stdout), "stderr")
+	require.NotContains(t, string(res.Stderr), "stdout")
+	require.Contains(t, string(res.Output), "stdout")
+	require.Contains(t, string(res.Output), "stderr")
+	require.Nil(t, res.Signal)
+	require.Positive(t, res.Duration)
+	require.Equal(t, cmd.Args(bin, "3"), res.Args)
+
+	res = r.RunWithResult(ctx, bin, "0").ExpectSuccess()
+	require.NoError(t, res.Err)
+	// Stdout and stderr are read separately, so the combined output of a
+	// result may have the lines of each in either order.
+	require.ElementsMatch(t, []string{"exiting on stdout", "exiting with 0 on stderr"}, strings.Split(strings.TrimSuffix(string(res.Output), "\n"), "\n"))
+	require.Equal(t, "exiting on stdout\nexiting with 0 on stderr\n", string(r.Run(ctx, bin, "0")))
+
+	res = r.RunWithResult(ctx, filepath.Join(dir, "missing"))
+	require.Error(t, res.Err)
+	require.Equal(t, -1, res.ExitCode)
+
+	if runtime.GOOS != "windows" {
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		res = r.RunWithResult(ctx, bin, "sleep")
+		require.Equal(t, -1, res.ExitCode)
+		require.Equal(t, os.Kill, res.Signal)
+	}
+}
+
+func TestStdin(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, echoSrc)
+	r := cmd.NewRunner(t, dir)
+	ctx := context.Background()
+
+	res := r.RunCmd(ctx, cmd.Args(bin).WithStdinBytes([]byte("hello\nworld\n"))).ExpectSuccess()
+	require.Equal(t, "echo: hello\necho: world\neof\n", string(res.Stdout))
+
+	res = r.RunCmd(ctx, cmd.Args(bin).WithStdin(strings.NewReader("reader\n"))).ExpectSuccess()
+	require.Equal(t, "echo: reader\neof\n", string(res.Stdout))
+
+	res = r.RunCmd(ctx, cmd.Args(bin)).ExpectSuccess()
+	require.Equal(t, "eof\n", string(res.Stdout))
+
+	stdin := r.StdinPipe()
+	wfirst := cmd.NewStdoutWatcher("echo: first")
+	wsecond := cmd.NewStdoutWatcher("echo: second")
+	weof := cmd.NewStdoutWatcher("eof")
+	c := r.Start(ctx, cmd.Args(bin).WithStdinPipe(stdin), wfirst, wsecond, weof)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := stdin.Write([]byte("first\n"))
+	require.NoError(t, err)
+	require.NoError(t, wfirst.Wait(waitCtx))
+
+	_, err = stdin.Write([]byte("second\n"))
+	require.NoError(t, err)
+	require.NoError(t, wsecond.Wait(waitCtx))
+
+	require.NoError(t, stdin.Close())
+	require.NoError(t, weof.Wait(waitCtx))
+	require.NoError(t, c.Wait())
+}
+
+var echoSrc = `
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+func main() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fmt.Println("echo:", scanner.Text())
+	}
+	fmt.Println("eof")
+}
+`
+
+var exitSrc = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+func main() {
+	if os.Args[1] == "sleep" {
+		time.Sleep(time.Minute)
+	}
+	code, _ := strconv.Atoi(os.Args[1])
+	fmt.Println("exiting on stdout")
+	fmt.Fprintf(os.Stderr, "exiting with %d on stderr\n", code)
+	os.Exit(code)
+}
+`
+
+// buildSrc builds the Go program in src and returns the path of its binary.
+func buildSrc(t *testing.T, dir, src string) string {
+	t.Helper()
+	srcPath := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(srcPath, []byte(src), 0644))
+	bin := filepath.Join(dir, "main")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	out, err := exec.Command("go", "build", "-o", bin, srcPath).CombinedOutput()
+	require.NoError(t, err, string(out))
+	return bin
+}
+
 var outSrc = `
 package main
 
@@ -1,62 +1,357 @@
 package cmd
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+)
 
 // Watcher is a helper for watching a command's output for a specific string.
 // The watcher can watch stdout, stderr or both. It is used by Runner to watch
 // for specific output from the commands. The Signal channel is signaled when
 // the match string is found.
+//
+// A watcher records every match, and never blocks the command's output from
+// being read, however many times it matches.
 type Watcher struct {
-	match  string
-	signal chan struct{}
-	stderr bool
-	stdout bool
+	matcher matcher
+	signal  chan struct{}
+	stderr  bool
+	stdout  bool
+	forbid  bool
+	state   *watchState
+}
+
+// watchState is the state shared by all copies of a Watcher.
+type watchState struct {
+	mu      sync.Mutex
+	matches []Match
+	waited  int
+	exited  bool
+	// changed is closed, and replaced, when there is a new match or the
+	// process exits.
+	changed chan struct{}
+}
+
+// Match is a line of output that matched a Watcher.
+type Match struct {
+	// Line is the line of output, without the line ending.
+	Line string
+	// Submatches are the submatches of a regular expression, as returned by
+	// regexp.Regexp.FindStringSubmatch. The first submatch is the text of the
+	// match, and the rest are the text of the capture groups. Submatches is
+	// nil if the watcher does not match regular expressions.
+	Submatches []string
+	// Stderr is true if the line was output on stderr, and false if it was
+	// output on stdout.
+	Stderr bool
+}
+
+// Submatch returns the text of the i'th capture group, or an empty string if
+// there is no such capture group.
+func (m Match) Submatch(i int) string {
+	if i < 1 || i >= len(m.Submatches) {
+		return ""
+	}
+	return m.Submatches[i]
 }
 
 // NewWatcher creates a Watcher that is signeled when matching a string from
 // stderr or stdout.
 func NewWatcher(match string) Watcher {
-	return Watcher{
-		match:  match,
-		signal: make(chan struct{}, 2),
-		stderr: true,
-		stdout: true,
-	}
+	return newWatcher(substringMatcher(match), true, true)
 }
 
 // NewStderrWatcher creates a Watcher that is signeled when matching a string from
 // stderr.
 func NewStderrWatcher(match string) Watcher {
-	return Watcher{
-		match:  match,
-		signal: make(chan struct{}, 1),
-		stderr: true,
-	}
+	return newWatcher(substringMatcher(match), true, false)
 }
 
 // NewStdoutWatcher creates a Watcher that is signeled when matching a string from
 // stdout.
 func NewStdoutWatcher(match string) Watcher {
+	return newWatcher(substringMatcher(match), false, true)
+}
+
+// NewRegexpWatcher creates a Watcher that is signaled when a line from stderr or
+// stdout matches the regular expression. The text of the match and of its
+// capture groups is returned by WaitMatch, and by Submatches.
+func NewRegexpWatcher(re *regexp.Regexp) Watcher {
+	return newWatcher(regexpMatcher{re}, true, true)
+}
+
+// NewSequenceWatcher creates a Watcher that is signaled when lines from stderr
+// or stdout match each of the regular expressions in order. Other lines may
+// appear between the matching lines. The submatches of a Match are those of
+// all the regular expressions, one after another, and its line is the last
+// matching line.
+func NewSequenceWatcher(res ...*regexp.Regexp) Watcher {
+	return newWatcher(&sequenceMatcher{res: res}, true, true)
+}
+
+// NewJSONLogWatcher creates a Watcher that is signaled when a line from stderr
+// or stdout is a JSON object, such as a structured log entry, that has all the
+// given fields with the given values. For example, a log entry can be matched
+// by its "level", "logger" and "msg" fields. Values that are not strings are
+// compared in their JSON encoding, so that the number 1 matches "1".
+func NewJSONLogWatcher(fields map[string]string) Watcher {
+	return newWatcher(jsonLogMatcher(fields), true, true)
+}
+
+func newWatcher(m matcher, stderr, stdout bool) Watcher {
 	return Watcher{
-		match:  match,
-		signal: make(chan struct{}, 1),
-		stdout: true,
+		matcher: m,
+		signal:  make(chan struct{}, 1),
+		stderr:  stderr,
+		stdout:  stdout,
+		state: &watchState{
+			changed: make(chan struct{}),
+		},
 	}
 }
 
+// StderrOnly returns a copy of the watcher that watches only stderr.
+func (w Watcher) StderrOnly() Watcher {
+	w.stderr = true
+	w.stdout = false
+	return w
+}
+
+// StdoutOnly returns a copy of the watcher that watches only stdout.
+func (w Watcher) StdoutOnly() Watcher {
+	w.stderr = false
+	w.stdout = true
+	return w
+}
+
+// MustNotMatch returns a copy of the watcher that fails the test if it
+// matches, such as when a command outputs "panic:" or an error that it never
+// should. The matches are still recorded and signaled.
+func (w Watcher) MustNotMatch() Watcher {
+	w.forbid = true
+	return w
+}
+
 // Wait waits for the watcher to be signaled for the the context to be
-// canceled. If the context is canceled then the context error is returned.
+// canceled. If the context is canceled then the context error is returned. If
+// the watched process exits before the watcher matches, then ErrProcessExited
+// is returned.
 func (w Watcher) Wait(ctx context.Context) error {
-	select {
-	case <-w.signal:
-	case <-ctx.Done():
-		return ctx.Err()
+	_, err := w.WaitMatch(ctx)
+	return err
+}
+
+// WaitMatch is the same as Wait, but also returns the line of output that
+// matched. Each call returns the next match that has not been returned by
+// Wait or WaitMatch. For example, the address a daemon is listening on can be
+// captured by a regular expression, and used to run other commands:
+//
+//	w := cmd.NewRegexpWatcher(regexp.MustCompile(`API server listening on (\S+)`))
+//	rnr.Start(ctx, cmd.Args("ipfs", "daemon"), w)
+//	m, err := w.WaitMatch(ctx)
+//	...
+//	rnr.Run(ctx, "ipfs", "--api", m.Submatch(1), "id")
+func (w Watcher) WaitMatch(ctx context.Context) (Match, error) {
+	for {
+		w.state.mu.Lock()
+		if w.state.waited < len(w.state.matches) {
+			m := w.state.matches[w.state.waited]
+			w.state.waited++
+			w.state.mu.Unlock()
+			return m, nil
+		}
+		exited := w.state.exited
+		changed := w.state.changed
+		w.state.mu.Unlock()
+		if exited {
+			return Match{}, ErrProcessExited
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return Match{}, ctx.Err()
+		}
 	}
-	return nil
+}
+
+// WaitCount waits for the watcher to have matched at least n times in total,
+// or for the context to be canceled. It does not affect which match is
+// returned next by WaitMatch. If the watched process exits before the watcher
+// has matched n times, then ErrProcessExited is returned.
+func (w Watcher) WaitCount(ctx context.Context, n int) error {
+	for {
+		w.state.mu.Lock()
+		count := len(w.state.matches)
+		exited := w.state.exited
+		changed := w.state.changed
+		w.state.mu.Unlock()
+		if count >= n {
+			return nil
+		}
+		if exited {
+			return ErrProcessExited
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Count returns the number of times the watcher has matched.
+func (w Watcher) Count() int {
+	w.state.mu.Lock()
+	defer w.state.mu.Unlock()
+	return len(w.state.matches)
+}
+
+// Matches returns all the matches of the watcher, in the order they were
+// matched.
+func (w Watcher) Matches() []Match {
+	w.state.mu.Lock()
+	defer w.state.mu.Unlock()
+	return append([]Match(nil), w.state.matches...)
 }
 
 // Signal returns the channel that is signaled when a line of output matches
-// this watcher.
+// this watcher. The channel is not signaled again until it has been received
+// from, so a single signal may be for more than one match. Use Count or
+// Matches to see how many times the watcher has matched.
 func (w Watcher) Signal() <-chan struct{} {
 	return w.signal
 }
+
+// Submatches returns the submatches of the most recent match, as described by
+// Match. Submatches returns nil if the watcher has not matched or does not
+// match regular expressions.
+func (w Watcher) Submatches() []string {
+	w.state.mu.Lock()
+	defer w.state.mu.Unlock()
+	if len(w.state.matches) == 0 {
+		return nil
+	}
+	return w.state.matches[len(w.state.matches)-1].Submatches
+}
+
+// match records and signals the line, without blocking, if it matches the
+// watcher. If the watcher must not match, then forbidden is called before the
+// match is recorded, so that the test fails before anything waiting for the
+// watcher sees the match.
+func (w Watcher) match(line string, stderr bool, forbidden func()) {
+	submatches, ok := w.matcher.match(line)
+	if !ok {
+		return
+	}
+	if w.forbid {
+		forbidden()
+	}
+	w.state.mu.Lock()
+	w.state.matches = append(w.state.matches, Match{
+		Line:       line,
+		Submatches: submatches,
+		Stderr:     stderr,
+	})
+	close(w.state.changed)
+	w.state.changed = make(chan struct{})
+	w.state.mu.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// exited records that the watched process has exited.
+func (w Watcher) exited() {
+	w.state.mu.Lock()
+	defer w.state.mu.Unlock()
+	w.state.exited = true
+	close(w.state.changed)
+	w.state.changed = make(chan struct{})
+}
+
+// matcher matches lines of output.
+type matcher interface {
+	// match returns true and any submatches if the line matches. It may be
+	// called concurrently for stdout and stderr.
+	match(line string) ([]string, bool)
+}
+
+// substringMatcher matches lines that contain a string, ignoring case.
+type substringMatcher string
+
+func (m substringMatcher) match(line string) ([]string, bool) {
+	return nil, strings.Contains(strings.ToLower(line), strings.ToLower(string(m)))
+}
+
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m regexpMatcher) match(line string) ([]string, bool) {
+	submatches := m.re.FindStringSubmatch(line)
+	return submatches, submatches != nil
+}
+
+type sequenceMatcher struct {
+	mu         sync.Mutex
+	res        []*regexp.Regexp
+	next       int
+	submatches []string
+}
+
+func (m *sequenceMatcher) match(line string) ([]string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.res) == 0 {
+		return nil, false
+	}
+	submatches := m.res[m.next].FindStringSubmatch(line)
+	if submatches == nil {
+		return nil, false
+	}
+	m.submatches = append(m.submatches, submatches...)
+	m.next++
+	if m.next < len(m.res) {
+		return nil, false
+	}
+	// Start again, to match the sequence the next time it appears.
+	submatches = m.submatches
+	m.submatches = nil
+	m.next = 0
+	return submatches, true
+}
+
+type jsonLogMatcher map[string]string
+
+func (m jsonLogMatcher) match(line string) ([]string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+	var entry map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return nil, false
+	}
+	for key, want := range m {
+		raw, ok := entry[key]
+		if !ok {
+			return nil, false
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		if s != want {
+			return nil, false
+		}
+	}
+	return nil, true
+}
@@ -0,0 +1,164 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-test/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+var logSrc = `
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println("Starting daemon")
+	fmt.Fprintln(os.Stderr, "listening on /ip4/127.0.0.1/tcp/4001")
+	fmt.Println("peer ID: 12D3KooWExample")
+	fmt.Fprintln(os.Stderr, "unrelated line")
+	fmt.Println("API server listening on /ip4/127.0.0.1/tcp/5001")
+	fmt.Fprintln(os.Stderr, ` + "`" + `{"level":"info","logger":"core","msg":"daemon ready","port":5001}` + "`" + `)
+	fmt.Println("Daemon is ready")
+}
+`
+
+func TestWatchers(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, logSrc)
+	r := cmd.NewRunner(t, dir)
+
+	wsub := cmd.NewStdoutWatcher("daemon is READY")
+	wre := cmd.NewRegexpWatcher(regexp.MustCompile(`^API server listening on (\S+)$`))
+	wreStderr := cmd.NewRegexpWatcher(regexp.MustCompile(`listening on /ip4/[^/]+/tcp/(\d+)`)).StderrOnly()
+	wseq := cmd.NewSequenceWatcher(
+		regexp.MustCompile(`^Starting`),
+		regexp.MustCompile(`peer ID: (\w+)`),
+		regexp.MustCompile(`tcp/(\d+)`),
+	).StdoutOnly()
+	wjson := cmd.NewJSONLogWatcher(map[string]string{
+		"level":  "info",
+		"logger": "core",
+		"msg":    "daemon ready",
+		"port":   "5001",
+	})
+	wjsonOther := cmd.NewJSONLogWatcher(map[string]string{"level": "error"})
+	wdaemon := cmd.NewRegexpWatcher(regexp.MustCompile(`(?i)daemon`)).StdoutOnly()
+	c := r.Start(context.Background(), cmd.Args(bin), wsub, wre, wreStderr, wseq, wjson, wjsonOther, wdaemon)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, wsub.Wait(ctx))
+	require.Nil(t, wsub.Submatches())
+
+	m, err := wre.WaitMatch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "API server listening on /ip4/127.0.0.1/tcp/5001", m.Line)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/5001", m.Submatch(1))
+	require.Empty(t, m.Submatch(2))
+	require.False(t, m.Stderr)
+	require.Equal(t, m.Submatches, wre.Submatches())
+
+	m, err = wreStderr.WaitMatch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "4001", m.Submatch(1))
+	require.True(t, m.Stderr)
+
+	m, err = wdaemon.WaitMatch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Starting daemon", m.Line)
+	m, err = wdaemon.WaitMatch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Daemon is ready", m.Line)
+
+	require.NoError(t, wseq.Wait(ctx))
+	require.Equal(t, []string{"Starting", "peer ID: 12D3KooWExample", "12D3KooWExample", "tcp/5001", "5001"}, wseq.Submatches())
+
+	require.NoError(t, wjson.Wait(ctx))
+	require.NoError(t, c.Wait())
+
+	require.ErrorIs(t, wjsonOther.Wait(ctx), cmd.ErrProcessExited)
+	require.Zero(t, wjsonOther.Count())
+}
+
+var repeatSrc = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func main() {
+	n, _ := strconv.Atoi(os.Args[2])
+	for i := 1; i <= n; i++ {
+		fmt.Println(os.Args[1], i)
+	}
+}
+`
+
+func TestWatcherCount(t *testing.T) {
+	dir := t.TempDir()
+	bin := buildSrc(t, dir, repeatSrc)
+	r := cmd.NewRunner(t, dir)
+
+	// Nothing receives from the watchers until all the output has been read,
+	// which would block the command if the watchers blocked reading its output.
+	w := cmd.NewWatcher("tick")
+	wnum := cmd.NewRegexpWatcher(regexp.MustCompile(`tick (\d+)`))
+	c := r.Start(context.Background(), cmd.Args(bin, "tick", "1000"), w, wnum)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, w.WaitCount(ctx, 1000))
+	require.NoError(t, c.Wait())
+	require.Equal(t, 1000, w.Count())
+	require.NoError(t, w.Wait(ctx))
+	<-w.Signal()
+
+	require.NoError(t, wnum.WaitCount(ctx, 1000))
+	matches := wnum.Matches()
+	require.Len(t, matches, 1000)
+	require.Equal(t, "1", matches[0].Submatch(1))
+	require.Equal(t, "1000", matches[999].Submatch(1))
+	m, err := wnum.WaitMatch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "1", m.Submatch(1))
+	m, err = wnum.WaitMatch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "2", m.Submatch(1))
+
+	require.ErrorIs(t, w.WaitCount(ctx, 1001), cmd.ErrProcessExited)
+}
+
+func TestMustNotMatch(t *testing.T) {
+	if os.Getenv("TEST_MUST_NOT_MATCH") != "" {
+		dir := t.TempDir()
+		bin := buildSrc(t, dir, repeatSrc)
+		r := cmd.NewRunner(t, dir)
+		w := cmd.NewStdoutWatcher("tick 2").MustNotMatch()
+		r.Start(context.Background(), cmd.Args(bin, "tick", "3"), w)
+		// The test finishes as soon as the watcher has matched, and must
+		// already have failed.
+		require.NoError(t, w.Wait(context.Background()))
+		return
+	}
+
+	dir := t.TempDir()
+	r := cmd.NewRunner(t, dir)
+	r.Env = append(r.Env, "TEST_MUST_NOT_MATCH=1")
+	res := r.RunWithResult(context.Background(), os.Args[0], "-test.run=^TestMustNotMatch$", "-test.v")
+	res.ExpectFailure()
+	res.ExpectStdoutContains("output must not match: tick 2")
+	res.ExpectStdoutContains("--- FAIL: TestMustNotMatch")
+}
@@ -1,6 +1,20 @@
 // go-test contains test utility code used across many different projects.
 //
 // The cmd package contains logic for running synchronous and asynchronous commands.
+// Started commands are watched for output, checked for readiness, monitored for
+// failures, and stopped when the test finishes. Go binaries under test can be
+// built once per test run, with coverage collected from them.
 //
 // The random package contains logic for generating random test data.
+//
+// The ports package contains logic for reserving free local network ports.
+//
+// The prop package contains logic for property-based testing with random
+// inputs that are shrunk to a smallest failing input.
+//
+// The fuzz package contains logic for seeding the corpus of Go fuzz tests with
+// random inputs.
+//
+// The golden package contains logic for comparing test output against golden
+// files.
 package test
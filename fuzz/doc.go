@@ -0,0 +1,8 @@
+// Package fuzz provides functionality for seeding the corpus of Go native
+// fuzz tests with inputs created by the random generators of go-test.
+//
+// Seed inputs are added to a *testing.F by Add, or written to a fuzz test's
+// testdata/fuzz corpus directory by WriteCorpus. The inputs are the same each
+// time they are created from the same seed, so that a corpus does not change
+// between test runs.
+package fuzz
@@ -0,0 +1,176 @@
+package fuzz
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/ipfs/go-test/random/ipld"
+)
+
+// DefaultSeed is the seed that Add creates inputs from.
+const DefaultSeed = 1
+
+// MaxBlockSize is the maximum size of a block payload input.
+const MaxBlockSize = 4096
+
+// Kind is a kind of random input.
+type Kind int
+
+const (
+	// Cids are binary CIDs.
+	Cids Kind = iota
+	// Multiaddrs are binary multiaddrs.
+	Multiaddrs
+	// PeerIDs are binary peer IDs.
+	PeerIDs
+	// Blocks are random block payloads of up to MaxBlockSize bytes.
+	Blocks
+	// DagCBOR are dag-cbor encodings of random IPLD nodes.
+	DagCBOR
+	// DagJSON are dag-json encodings of random IPLD nodes.
+	DagJSON
+)
+
+var kindNames = []string{
+	Cids:       "cids",
+	Multiaddrs: "multiaddrs",
+	PeerIDs:    "peerids",
+	Blocks:     "blocks",
+	DagCBOR:    "dag-cbor",
+	DagJSON:    "dag-json",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// ParseKind returns the Kind that has the given name, as returned by
+// Kind.String.
+func ParseKind(name string) (Kind, error) {
+	for i, kindName := range kindNames {
+		if name == kindName {
+			return Kind(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown kind %q", name)
+}
+
+// Kinds returns all kinds of random input.
+func Kinds() []Kind {
+	kinds := make([]Kind, len(kindNames))
+	for i := range kinds {
+		kinds[i] = Kind(i)
+	}
+	return kinds
+}
+
+// Add adds n random inputs of the given kind, created from DefaultSeed, to the
+// seed corpus of f. Each input is a single []byte argument of the fuzz target.
+func Add(f *testing.F, kind Kind, n int) {
+	f.Helper()
+	AddSeeded(f, kind, n, DefaultSeed)
+}
+
+// AddSeeded is the same as Add, but creates the inputs from the given seed.
+func AddSeeded(f *testing.F, kind Kind, n int, seed int64) {
+	f.Helper()
+	inputs, err := Inputs(kind, n, seed)
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, input := range inputs {
+		f.Add(input)
+	}
+}
+
+// Inputs returns n random inputs of the given kind, created from seed. The
+// same inputs are returned each time for the same seed.
+func Inputs(kind Kind, n int, seed int64) ([][]byte, error) {
+	rng := random.NewSeededRand(seed)
+	inputs := make([][]byte, n)
+	switch kind {
+	case Cids:
+		for i := range inputs {
+			inputs[i] = random.CidFromRand(rng).Bytes()
+		}
+	case Multiaddrs:
+		cfg := random.DefaultMultiaddrConfig()
+		for i := range inputs {
+			inputs[i] = random.MultiaddrFromRand(rng, cfg).Bytes()
+		}
+	case PeerIDs:
+		for i := range inputs {
+			inputs[i] = []byte(random.PeerFromRand(rng))
+		}
+	case Blocks:
+		for i := range inputs {
+			inputs[i] = make([]byte, blockSize(rng))
+			rng.Read(inputs[i])
+		}
+	case DagCBOR, DagJSON:
+		cfg := ipld.DefaultConfig()
+		for cfg.Seed == 0 {
+			cfg.Seed = rng.Int63()
+		}
+		nodes, err := ipld.Nodes(n, cfg)
+		if err != nil {
+			return nil, err
+		}
+		encode := ipld.EncodeDagCBOR
+		if kind == DagJSON {
+			encode = ipld.EncodeDagJSON
+		}
+		for i, node := range nodes {
+			if inputs[i], err = encode(node); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %d", kind)
+	}
+	return inputs, nil
+}
+
+// blockSize returns a random block size, which is often a size at which
+// chunkers and buffers change behavior.
+func blockSize(rng *rand.Rand) int {
+	if rng.Intn(4) == 0 {
+		edges := []int{0, 1, 255, 256, 1023, 1024, 1025, MaxBlockSize}
+		return edges[rng.Intn(len(edges))]
+	}
+	return rng.Intn(MaxBlockSize + 1)
+}
+
+// WriteCorpus writes each input to a file in dir, in the format of the Go
+// fuzzing corpus for a fuzz target that takes a single []byte argument. The
+// dir for the fuzz test FuzzXxx of a package is testdata/fuzz/FuzzXxx in the
+// package directory. Each file is named by the hash of its contents, as Go
+// names new corpus files, so writing the same inputs again does not create
+// more files.
+func WriteCorpus(dir string, inputs [][]byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, input := range inputs {
+		data := MarshalCorpusEntry(input)
+		name := fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalCorpusEntry returns the contents of a Go fuzzing corpus file for a
+// single []byte argument.
+func MarshalCorpusEntry(input []byte) []byte {
+	return fmt.Appendf(nil, "go test fuzz v1\n[]byte(%q)\n", input)
+}
@@ -0,0 +1,112 @@
+package fuzz_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-test/fuzz"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzCid(f *testing.F) {
+	fuzz.Add(f, fuzz.Cids, 10)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c, err := cid.Cast(data)
+		if err != nil {
+			return
+		}
+		require.Equal(t, data, c.Bytes())
+	})
+}
+
+func TestInputs(t *testing.T) {
+	for _, kind := range fuzz.Kinds() {
+		inputs, err := fuzz.Inputs(kind, 20, fuzz.DefaultSeed)
+		require.NoError(t, err)
+		require.Len(t, inputs, 20)
+
+		again, err := fuzz.Inputs(kind, 20, fuzz.DefaultSeed)
+		require.NoError(t, err)
+		require.Equal(t, inputs, again, "inputs not deterministic for %s", kind)
+
+		for _, input := range inputs {
+			switch kind {
+			case fuzz.Cids:
+				_, err = cid.Cast(input)
+			case fuzz.Multiaddrs:
+				_, err = multiaddr.NewMultiaddrBytes(input)
+			case fuzz.PeerIDs:
+				_, err = peer.IDFromBytes(input)
+			case fuzz.Blocks:
+				require.LessOrEqual(t, len(input), fuzz.MaxBlockSize)
+			case fuzz.DagCBOR:
+				err = dagcbor.Decode(basicnode.Prototype.Any.NewBuilder(), bytes.NewReader(input))
+			case fuzz.DagJSON:
+				err = dagjson.Decode(basicnode.Prototype.Any.NewBuilder(), bytes.NewReader(input))
+			}
+			require.NoError(t, err, "invalid %s input", kind)
+		}
+	}
+
+	other, err := fuzz.Inputs(fuzz.Cids, 20, 2)
+	require.NoError(t, err)
+	inputs, err := fuzz.Inputs(fuzz.Cids, 20, fuzz.DefaultSeed)
+	require.NoError(t, err)
+	require.NotEqual(t, inputs, other)
+
+	_, err = fuzz.Inputs(fuzz.Kind(100), 1, fuzz.DefaultSeed)
+	require.Error(t, err)
+}
+
+func TestParseKind(t *testing.T) {
+	for _, kind := range fuzz.Kinds() {
+		parsed, err := fuzz.ParseKind(kind.String())
+		require.NoError(t, err)
+		require.Equal(t, kind, parsed)
+	}
+	_, err := fuzz.ParseKind("unknown")
+	require.Error(t, err)
+}
+
+func TestWriteCorpus(t *testing.T) {
+	inputs, err := fuzz.Inputs(fuzz.Blocks, 10, fuzz.DefaultSeed)
+	require.NoError(t, err)
+	inputs = append(inputs, []byte("\x00\xff\"\n"))
+
+	dir := filepath.Join(t.TempDir(), "testdata", "fuzz", "FuzzXxx")
+	require.NoError(t, fuzz.WriteCorpus(dir, inputs))
+	require.NoError(t, fuzz.WriteCorpus(dir, inputs))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	unique := make(map[string]struct{})
+	for _, input := range inputs {
+		unique[string(input)] = struct{}{}
+	}
+	require.Len(t, entries, len(unique))
+
+	found := make(map[string]struct{})
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		header, value, ok := strings.Cut(string(data), "\n")
+		require.True(t, ok)
+		require.Equal(t, "go test fuzz v1", header)
+		value = strings.TrimSuffix(value, "\n")
+		require.True(t, strings.HasPrefix(value, "[]byte(") && strings.HasSuffix(value, ")"))
+		s, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(value, "[]byte("), ")"))
+		require.NoError(t, err)
+		found[s] = struct{}{}
+	}
+	require.Equal(t, unique, found)
+}
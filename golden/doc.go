@@ -0,0 +1,18 @@
+// Package golden provides functionality for comparing test output against
+// expected output stored in golden files.
+//
+// A golden file is stored at testdata/<name>.golden in the directory of the
+// package being tested. When output does not match its golden file, the test
+// fails and shows a unified diff of the expected and actual output. Golden
+// files are created or updated with the actual output, instead of compared,
+// when tests are run with the -golden.update flag or with the GOLDEN_UPDATE
+// environment variable set to true:
+//
+//	go test . -golden.update
+//	GOLDEN_UPDATE=1 go test ./...
+//
+// Output that contains random data, such as that created by the random and
+// random/files packages, matches its golden file only when the random data is
+// reproduced from the same seed each time. Set the seed with random.SetSeed,
+// or the Seed setting of a configuration, before creating the data.
+package golden
@@ -0,0 +1,249 @@
+package golden
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// UpdateEnv is the environment variable that, when set to true, causes golden
+// files to be updated.
+const UpdateEnv = "GOLDEN_UPDATE"
+
+// Dir is the directory that contains golden files, relative to the directory
+// of the package being tested.
+var Dir = "testdata"
+
+// update is the -golden.update flag. It is namespaced so that it does not
+// conflict with an -update flag defined by the package being tested.
+var update = flag.Bool("golden.update", false, "update golden files")
+
+// Update returns true if golden files are to be updated instead of compared,
+// because the -golden.update flag is set or the UpdateEnv environment variable
+// is true.
+func Update() bool {
+	if *update {
+		return true
+	}
+	env, _ := strconv.ParseBool(os.Getenv(UpdateEnv))
+	return env
+}
+
+// Path returns the path of the golden file with the given name.
+func Path(name string) string {
+	return filepath.Join(Dir, filepath.FromSlash(name)+".golden")
+}
+
+// Assert compares got with the contents of the named golden file, and fails
+// the test if they differ. Text is shown as a unified diff of lines, and other
+// data as a unified diff of hex dumps.
+func Assert(t testing.TB, name string, got []byte) {
+	t.Helper()
+	if Update() {
+		write(t, name, got)
+		return
+	}
+	want := read(t, name)
+	if want == nil || bytes.Equal(got, want) {
+		return
+	}
+	if isText(got) && isText(want) {
+		t.Fatalf("output does not match golden file %s:\n%s", Path(name), diff(string(want), string(got)))
+		return
+	}
+	t.Fatalf("output does not match golden file %s:\n%s", Path(name), diff(hex.Dump(want), hex.Dump(got)))
+}
+
+// AssertText compares the text got with the contents of the named golden
+// file, and fails the test if they differ. Windows line endings are converted
+// to Unix line endings before comparing, so that golden files checked out on
+// Windows still match.
+func AssertText(t testing.TB, name, got string) {
+	t.Helper()
+	got = normalizeText(got)
+	if Update() {
+		write(t, name, []byte(got))
+		return
+	}
+	want := read(t, name)
+	if want == nil {
+		return
+	}
+	if wantText := normalizeText(string(want)); got != wantText {
+		t.Fatalf("output does not match golden file %s:\n%s", Path(name), diff(wantText, got))
+	}
+}
+
+// AssertJSON compares got, as normalized JSON, with the normalized JSON in the
+// named golden file, and fails the test if they differ. If got is a []byte,
+// json.RawMessage or string, then it is JSON that is parsed, otherwise it is a
+// value that is marshaled to JSON. JSON is normalized by sorting object keys
+// and indenting it, so that formatting and key order do not matter.
+func AssertJSON(t testing.TB, name string, got any) {
+	t.Helper()
+	var data []byte
+	switch v := got.(type) {
+	case []byte:
+		data = v
+	case json.RawMessage:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			t.Fatalf("cannot marshal output to json: %s", err)
+			return
+		}
+	}
+	gotJSON, err := normalizeJSON(data)
+	if err != nil {
+		t.Fatalf("output is not valid json: %s", err)
+		return
+	}
+	if Update() {
+		write(t, name, gotJSON)
+		return
+	}
+	want := read(t, name)
+	if want == nil {
+		return
+	}
+	wantJSON, err := normalizeJSON(want)
+	if err != nil {
+		t.Fatalf("golden file %s is not valid json: %s", Path(name), err)
+		return
+	}
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Fatalf("output does not match golden file %s:\n%s", Path(name), diff(string(wantJSON), string(gotJSON)))
+	}
+}
+
+// AssertDir compares a manifest of the files and directories in dir with the
+// named golden file, and fails the test if they differ. The manifest lists the
+// relative path of each directory, and the relative path, size and SHA-256
+// hash of each file, in lexical order. This is useful for checking file trees
+// created by random/files with a fixed seed.
+func AssertDir(t testing.TB, name, dir string) {
+	t.Helper()
+	manifest, err := Manifest(dir)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	AssertText(t, name, manifest)
+}
+
+// Manifest returns the manifest of the files and directories in dir, as
+// compared by AssertDir.
+func Manifest(dir string) (string, error) {
+	var b strings.Builder
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			fmt.Fprintf(&b, "%s/\n", rel)
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s %d %x\n", rel, len(data), sha256.Sum256(data))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// read returns the contents of the named golden file. If the file cannot be
+// read, the test fails and nil is returned.
+func read(t testing.TB, name string) []byte {
+	t.Helper()
+	want, err := os.ReadFile(Path(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("golden file %s does not exist, run with -golden.update or %s=1 to create it", Path(name), UpdateEnv)
+		} else {
+			t.Fatal(err)
+		}
+		return nil
+	}
+	return want
+}
+
+func write(t testing.TB, name string, data []byte) {
+	t.Helper()
+	path := Path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("updated golden file %s", path)
+}
+
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) == -1
+}
+
+func normalizeText(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+func normalizeJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after top-level value")
+	}
+	// Map keys are sorted when marshaled.
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func diff(want, got string) string {
+	s, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(want),
+		B:        difflib.SplitLines(got),
+		FromFile: "want",
+		ToFile:   "got",
+		Context:  3,
+	})
+	if err != nil {
+		return err.Error()
+	}
+	return s
+}
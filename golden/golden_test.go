@@ -0,0 +1,112 @@
+package golden_test
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-test/golden"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipfs/go-test/random/files"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a testing.TB that records a failure instead of failing the test.
+type recorder struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Logf(format string, args ...any) {}
+
+func (r *recorder) Fatal(args ...any) {
+	r.failed = true
+	r.msg = fmt.Sprint(args...)
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+// A test package that imports golden can define its own -update flag.
+var _ = flag.Bool("update", false, "update test data")
+
+// useTempDir uses golden files in a temporary directory, which are compared
+// even when the tests are run with -golden.update.
+func useTempDir(t *testing.T) {
+	dir := golden.Dir
+	golden.Dir = t.TempDir()
+	update := flag.Lookup("golden.update").Value.String()
+	require.NoError(t, flag.Set("golden.update", "false"))
+	t.Setenv(golden.UpdateEnv, "")
+	t.Cleanup(func() {
+		golden.Dir = dir
+		flag.Set("golden.update", update)
+	})
+}
+
+func TestAssert(t *testing.T) {
+	random.SetSeed(1)
+	golden.Assert(t, "bytes", random.Bytes(64))
+	golden.AssertText(t, "text", "hello\nworld\n")
+	golden.AssertText(t, "text", "hello\r\nworld\r\n")
+	golden.AssertJSON(t, "json", map[string]any{"b": []int{1, 2}, "a": "<x>"})
+	golden.AssertJSON(t, "json", `{"a":"<x>","b":[1,2]}`)
+	golden.AssertJSON(t, "json", []byte(`{"b": [1, 2], "a": "<x>"}`))
+}
+
+func TestAssertDir(t *testing.T) {
+	cfg := files.DefaultConfig()
+	cfg.Files = 3
+	cfg.Dirs = 2
+	cfg.Seed = 1701
+	dir := t.TempDir()
+	require.NoError(t, files.Create(cfg, dir))
+	golden.AssertDir(t, "tree", dir)
+}
+
+func TestMismatch(t *testing.T) {
+	useTempDir(t)
+	t.Setenv(golden.UpdateEnv, "1")
+	golden.AssertText(t, "text", "one\ntwo\nthree\n")
+	golden.Assert(t, "sub/bytes", []byte{0, 1, 2, 3})
+	golden.AssertJSON(t, "json", `{"a":1}`)
+	require.FileExists(t, filepath.Join(golden.Dir, "sub", "bytes.golden"))
+	t.Setenv(golden.UpdateEnv, "")
+
+	r := &recorder{TB: t}
+	golden.AssertText(r, "text", "one\n2\nthree\n")
+	require.True(t, r.failed)
+	require.Contains(t, r.msg, "--- want\n+++ got\n")
+	require.Contains(t, r.msg, "-two\n+2\n")
+
+	r = &recorder{TB: t}
+	golden.Assert(r, "sub/bytes", []byte{0, 1, 2, 4})
+	require.True(t, r.failed)
+	require.Contains(t, r.msg, "-00000000  00 01 02 03")
+	require.Contains(t, r.msg, "+00000000  00 01 02 04")
+
+	r = &recorder{TB: t}
+	golden.AssertJSON(r, "json", `{"a":2}`)
+	require.True(t, r.failed)
+	require.Contains(t, r.msg, `-  "a": 1`)
+
+	r = &recorder{TB: t}
+	golden.AssertJSON(r, "json", `{"a":`)
+	require.True(t, r.failed)
+	require.Contains(t, r.msg, "not valid json")
+}
+
+func TestMissing(t *testing.T) {
+	useTempDir(t)
+	r := &recorder{TB: t}
+	golden.AssertText(r, "missing", "text")
+	require.True(t, r.failed)
+	require.Contains(t, r.msg, "does not exist")
+	require.Contains(t, r.msg, golden.UpdateEnv)
+}
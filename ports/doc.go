@@ -0,0 +1,7 @@
+// Package ports provides functionality to reserve free local network ports.
+//
+// Reserved ports are held open until they are released, so that they are not
+// given to anything else, and are then handed to a process that listens on
+// them. A port is never reserved more than once within a test binary, even by
+// tests running in parallel.
+package ports
@@ -0,0 +1,160 @@
+package ports
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Loopback4 is the IPv4 loopback host that ports are reserved on.
+	Loopback4 = "127.0.0.1"
+	// Loopback6 is the IPv6 loopback host that ports are reserved on.
+	Loopback6 = "::1"
+
+	// maxAttempts is the number of times to try finding a port that has not
+	// already been reserved.
+	maxAttempts = 64
+)
+
+var reserved = struct {
+	mutex sync.Mutex
+	ports map[portKey]struct{}
+}{
+	ports: map[portKey]struct{}{},
+}
+
+type portKey struct {
+	network string
+	number  int
+}
+
+// Port is a free local port that is reserved by holding a socket bound to it
+// until Release is called.
+type Port struct {
+	// Network is "tcp" or "udp".
+	Network string
+	// IP is the loopback address that the port was reserved on.
+	IP netip.Addr
+	// Number is the port number.
+	Number int
+
+	mutex  sync.Mutex
+	holder io.Closer
+}
+
+// Reserve reserves a free port for the given network, "tcp" or "udp", on the
+// given loopback host, Loopback4 or Loopback6. The port is released when the
+// test finishes, if it has not been released already.
+func Reserve(t *testing.T, network, host string) *Port {
+	t.Helper()
+
+	ip, err := netip.ParseAddr(host)
+	require.NoError(t, err)
+
+	reserved.mutex.Lock()
+	defer reserved.mutex.Unlock()
+
+	// Sockets for ports that were already reserved are held open until a new
+	// port is found, so that the same ports are not given out again.
+	var discard []io.Closer
+	defer func() {
+		for _, c := range discard {
+			c.Close()
+		}
+	}()
+
+	for range maxAttempts {
+		holder, number, err := listen(network, ip)
+		require.NoError(t, err)
+
+		key := portKey{network: network, number: number}
+		if _, ok := reserved.ports[key]; ok {
+			discard = append(discard, holder)
+			continue
+		}
+		reserved.ports[key] = struct{}{}
+
+		p := &Port{
+			Network: network,
+			IP:      ip,
+			Number:  number,
+			holder:  holder,
+		}
+		t.Cleanup(func() { p.Release() })
+		return p
+	}
+	t.Fatalf("could not find unreserved %s port after %d attempts", network, maxAttempts)
+	return nil
+}
+
+// ReserveN reserves n free ports. See Reserve.
+func ReserveN(t *testing.T, n int, network, host string) []*Port {
+	t.Helper()
+
+	ports := make([]*Port, n)
+	for i := range n {
+		ports[i] = Reserve(t, network, host)
+	}
+	return ports
+}
+
+// Release closes the socket holding the port, so that a process can listen on
+// it, and returns the port number. The port remains reserved and is not given
+// out again by Reserve.
+func (p *Port) Release() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.holder != nil {
+		p.holder.Close()
+		p.holder = nil
+	}
+	return p.Number
+}
+
+// Addr returns the "host:port" address of the port.
+func (p *Port) Addr() string {
+	return net.JoinHostPort(p.IP.String(), strconv.Itoa(p.Number))
+}
+
+// Multiaddr returns the listen multiaddr of the port, such as
+// /ip4/127.0.0.1/tcp/4001 or /ip6/::1/udp/4001. Protocols that run over the
+// port, such as /quic-v1, may be appended to it.
+func (p *Port) Multiaddr() multiaddr.Multiaddr {
+	ipProto := "ip4"
+	if p.IP.Is6() {
+		ipProto = "ip6"
+	}
+	return multiaddr.StringCast(fmt.Sprintf("/%s/%s/%s/%d", ipProto, p.IP, p.Network, p.Number))
+}
+
+func (p *Port) String() string {
+	return p.Network + "/" + p.Addr()
+}
+
+func listen(network string, ip netip.Addr) (io.Closer, int, error) {
+	addr := net.JoinHostPort(ip.String(), "0")
+	switch network {
+	case "tcp":
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			return nil, 0, err
+		}
+		return ln, ln.Addr().(*net.TCPAddr).Port, nil
+	case "udp":
+		conn, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return nil, 0, err
+		}
+		return conn, conn.LocalAddr().(*net.UDPAddr).Port, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported network %q, must be tcp or udp", network)
+}
@@ -0,0 +1,90 @@
+package ports_test
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-test/ports"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserve(t *testing.T) {
+	p := ports.Reserve(t, "tcp", ports.Loopback4)
+	require.NotZero(t, p.Number)
+
+	// Port is held until released.
+	_, err := net.Listen("tcp", p.Addr())
+	require.Error(t, err)
+
+	require.Equal(t, p.Number, p.Release())
+	ln, err := net.Listen("tcp", p.Addr())
+	require.NoError(t, err)
+	ln.Close()
+
+	require.Equal(t, p.Number, p.Release())
+	t.Log("reserved port:", p)
+}
+
+func TestReserveUDP(t *testing.T) {
+	p := ports.Reserve(t, "udp", ports.Loopback4)
+	_, err := net.ListenPacket("udp", p.Addr())
+	require.Error(t, err)
+
+	p.Release()
+	conn, err := net.ListenPacket("udp", p.Addr())
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestMultiaddr(t *testing.T) {
+	p := ports.Reserve(t, "tcp", ports.Loopback4)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/"+strconv.Itoa(p.Number), p.Multiaddr().String())
+
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("ipv6 loopback not available")
+	}
+	ln.Close()
+	p = ports.Reserve(t, "udp", ports.Loopback6)
+	require.Equal(t, "/ip6/::1/udp/"+strconv.Itoa(p.Number), p.Multiaddr().String())
+}
+
+func TestReserveUnique(t *testing.T) {
+	const (
+		workers = 8
+		perWork = 16
+	)
+	// Each worker is a parallel subtest, so that Reserve can fail the test
+	// from the worker's goroutine.
+	var (
+		mutex    sync.Mutex
+		reserved [][]*ports.Port
+	)
+	t.Run("workers", func(t *testing.T) {
+		for i := range workers {
+			t.Run(strconv.Itoa(i), func(t *testing.T) {
+				t.Parallel()
+				ps := ports.ReserveN(t, perWork, "tcp", ports.Loopback4)
+				for _, p := range ps {
+					// Release immediately so that the OS may offer the same port again.
+					p.Release()
+				}
+				mutex.Lock()
+				reserved = append(reserved, ps)
+				mutex.Unlock()
+			})
+		}
+	})
+
+	seen := make(map[int]struct{}, workers*perWork)
+	for _, ps := range reserved {
+		for _, p := range ps {
+			_, dup := seen[p.Number]
+			require.False(t, dup, "port %d reserved more than once", p.Number)
+			seen[p.Number] = struct{}{}
+		}
+	}
+	require.Len(t, seen, workers*perWork)
+}
@@ -0,0 +1,12 @@
+// Package prop provides property-based testing, in the style of QuickCheck.
+//
+// A property is a function that checks something that must be true for every
+// input. Check calls a property with many random inputs, created by a
+// generator, and fails the test if the property does not hold for any of
+// them. The failing input is then shrunk to the smallest input found that
+// still fails, which is reported along with the seed that reproduces it.
+//
+// Generators are provided for the random test data that the random and
+// random/files packages create, and for slices of any generated value.
+// Random values are not cryptographically secure.
+package prop
@@ -0,0 +1,222 @@
+package prop
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipfs/go-test/random/files"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Int returns a generator of ints between lo and hi inclusive. Values are
+// shrunk toward lo, or toward 0 if that is between lo and hi.
+func Int(lo, hi int) Gen[int] {
+	if hi < lo {
+		panic("prop: hi is less than lo")
+	}
+	target := lo
+	if lo <= 0 && hi >= 0 {
+		target = 0
+	}
+	// The span is computed as unsigned, since hi-lo overflows an int when the
+	// range is more than half of all ints.
+	span := uint64(hi) - uint64(lo)
+	return Gen[int]{
+		Generate: func(rng *rand.Rand) int {
+			if span < math.MaxInt64 {
+				return lo + int(rng.Int63n(int64(span)+1))
+			}
+			for {
+				if n := rng.Uint64(); n <= span {
+					return lo + int(n)
+				}
+			}
+		},
+		Shrink: func(v int) []int {
+			return shrinkInt(v, target)
+		},
+	}
+}
+
+// Bytes returns a generator of byte slices that have up to maxSize random
+// bytes. Slices are shrunk by removing bytes, and then by reducing the value of
+// each byte toward 0.
+func Bytes(maxSize int) Gen[[]byte] {
+	return Gen[[]byte]{
+		Generate: func(rng *rand.Rand) []byte {
+			b := make([]byte, rng.Intn(maxSize+1))
+			rng.Read(b)
+			return b
+		},
+		Shrink: func(v []byte) [][]byte {
+			return shrinkSlice(v, func(b byte) []byte {
+				return shrinkInt(b, 0)
+			})
+		},
+	}
+}
+
+// Cid returns a generator of random CIDs, created by random.CidFromRand. CIDs
+// are not shrunk.
+func Cid() Gen[cid.Cid] {
+	return Gen[cid.Cid]{Generate: random.CidFromRand}
+}
+
+// Cids returns a generator of slices of up to maxLen random CIDs.
+func Cids(maxLen int) Gen[[]cid.Cid] {
+	return SliceOf(Cid(), maxLen)
+}
+
+// Peer returns a generator of random Ed25519 peer IDs, created by
+// random.PeerFromRand. Peer IDs are not shrunk.
+func Peer() Gen[peer.ID] {
+	return Gen[peer.ID]{Generate: random.PeerFromRand}
+}
+
+// Peers returns a generator of slices of up to maxLen random peer IDs.
+func Peers(maxLen int) Gen[[]peer.ID] {
+	return SliceOf(Peer(), maxLen)
+}
+
+// Multiaddr returns a generator of random IPv4 TCP multiaddrs, like those
+// created by random.Multiaddrs. Multiaddrs are not shrunk.
+func Multiaddr() Gen[multiaddr.Multiaddr] {
+	return MultiaddrWithConfig(random.DefaultMultiaddrConfig())
+}
+
+// MultiaddrWithConfig returns a generator of random multiaddrs created by
+// random.MultiaddrFromRand according to the provided configuration.
+// Multiaddrs are not shrunk.
+func MultiaddrWithConfig(cfg random.MultiaddrConfig) Gen[multiaddr.Multiaddr] {
+	return Gen[multiaddr.Multiaddr]{
+		Generate: func(rng *rand.Rand) multiaddr.Multiaddr {
+			return random.MultiaddrFromRand(rng, cfg)
+		},
+	}
+}
+
+// Multiaddrs returns a generator of slices of up to maxLen random multiaddrs.
+func Multiaddrs(maxLen int) Gen[[]multiaddr.Multiaddr] {
+	return SliceOf(Multiaddr(), maxLen)
+}
+
+// FileTree returns a generator of random configurations for creating file
+// trees with files.Create. The Depth, Dirs, Files and FileSize of each
+// configuration are at most those of limit, and its other settings are
+// copied from limit, except for Seed which is random. A Depth of 0 in limit is
+// the same as 1, and the Depth is always 1 if Dirs is 0, since there are no
+// subdirectories. Configurations are shrunk by reducing Depth, Dirs, Files and
+// FileSize, while keeping the same Seed.
+func FileTree(limit files.Config) Gen[files.Config] {
+	if limit.Depth < 0 || limit.Dirs < 0 || limit.Files < 0 || limit.FileSize < 0 {
+		panic("prop: file tree limit is negative")
+	}
+	maxDepth := max(limit.Depth, 1)
+	if limit.Dirs == 0 {
+		maxDepth = 1
+	}
+	return Gen[files.Config]{
+		Generate: func(rng *rand.Rand) files.Config {
+			cfg := limit
+			cfg.Depth = 1 + rng.Intn(maxDepth)
+			cfg.Dirs = rng.Intn(limit.Dirs + 1)
+			if cfg.Depth > 1 {
+				cfg.Dirs = max(cfg.Dirs, 1)
+			}
+			cfg.Files = rng.Intn(limit.Files + 1)
+			cfg.FileSize = rng.Int63n(limit.FileSize + 1)
+			for cfg.Seed == 0 {
+				cfg.Seed = rng.Int63()
+			}
+			return cfg
+		},
+		Shrink: shrinkFileTree,
+	}
+}
+
+func shrinkFileTree(cfg files.Config) []files.Config {
+	var out []files.Config
+	for _, depth := range shrinkInt(cfg.Depth, 1) {
+		c := cfg
+		c.Depth = depth
+		if depth == 1 {
+			c.Dirs = 0
+		}
+		out = append(out, c)
+	}
+	minDirs := 0
+	if cfg.Depth > 1 {
+		minDirs = 1
+	}
+	for _, dirs := range shrinkInt(cfg.Dirs, minDirs) {
+		c := cfg
+		c.Dirs = dirs
+		out = append(out, c)
+	}
+	for _, n := range shrinkInt(cfg.Files, 0) {
+		c := cfg
+		c.Files = n
+		out = append(out, c)
+	}
+	for _, size := range shrinkInt(cfg.FileSize, 0) {
+		c := cfg
+		c.FileSize = size
+		out = append(out, c)
+	}
+	return out
+}
+
+// SliceOf returns a generator of slices of up to maxLen values created by gen.
+// Slices are shrunk by removing values, and then by shrinking each value with
+// gen.
+func SliceOf[T any](gen Gen[T], maxLen int) Gen[[]T] {
+	return Gen[[]T]{
+		Generate: func(rng *rand.Rand) []T {
+			s := make([]T, rng.Intn(maxLen+1))
+			for i := range s {
+				s[i] = gen.Generate(rng)
+			}
+			return s
+		},
+		Shrink: func(v []T) [][]T {
+			return shrinkSlice(v, gen.Shrink)
+		},
+	}
+}
+
+// shrinkInt returns values between target and v, closest to target first.
+func shrinkInt[T ~int | ~int64 | ~uint8](v, target T) []T {
+	var out []T
+	for diff := v - target; diff != 0; diff /= 2 {
+		out = append(out, v-diff)
+	}
+	return out
+}
+
+// shrinkSlice returns slices with chunks removed from v, largest chunks first,
+// followed by slices with one element shrunk by shrinkElem.
+func shrinkSlice[T any](v []T, shrinkElem func(T) []T) [][]T {
+	var out [][]T
+	for size := len(v); size > 0; size /= 2 {
+		for start := 0; start+size <= len(v); start += size {
+			s := make([]T, 0, len(v)-size)
+			s = append(s, v[:start]...)
+			out = append(out, append(s, v[start+size:]...))
+		}
+	}
+	if shrinkElem == nil {
+		return out
+	}
+	for i, elem := range v {
+		for _, smaller := range shrinkElem(elem) {
+			s := make([]T, len(v))
+			copy(s, v)
+			s[i] = smaller
+			out = append(out, s)
+		}
+	}
+	return out
+}
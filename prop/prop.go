@@ -0,0 +1,165 @@
+package prop
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+)
+
+// Gen creates random values of type T, and smaller values from them.
+type Gen[T any] struct {
+	// Generate returns a random value created from rng. The value must only
+	// depend on rng, so that it can be created again from the same seed.
+	Generate func(rng *rand.Rand) T
+	// Shrink returns values that are smaller than v, in the order they are to
+	// be tried. The smallest values should come first. If nil, values are not
+	// shrunk.
+	Shrink func(v T) []T
+}
+
+// Config contains settings for checking a property.
+type Config struct {
+	// Iterations is the number of random values that the property is checked
+	// with.
+	Iterations int
+	// MaxShrinks is the maximum number of times the property is called while
+	// shrinking a failing value.
+	MaxShrinks int
+	// Seed sets the seed for the values created by the first iteration when
+	// set to a non-zero value. Each later iteration uses the next seed. A
+	// failure reports the seed of the failing iteration, which reproduces the
+	// failure on the first iteration when set here.
+	Seed int64
+}
+
+// DefaultConfig returns default settings for checking a property.
+func DefaultConfig() Config {
+	return Config{
+		Iterations: 100,
+		MaxShrinks: 1000,
+	}
+}
+
+// Failure describes a value for which a property does not hold.
+type Failure[T any] struct {
+	// Seed is the seed that the failing value was created from.
+	Seed int64
+	// Iteration is the iteration that the failing value was created in,
+	// starting at 1.
+	Iteration int
+	// Original is the failing value as it was created.
+	Original T
+	// Shrunk is the smallest failing value found by shrinking Original.
+	Shrunk T
+	// Shrinks is the number of times that the failing value was shrunk.
+	Shrinks int
+	// Err is the error returned by the property for the shrunk value.
+	Err error
+}
+
+func (f *Failure[T]) Error() string {
+	return fmt.Sprintf("property failed on iteration %d with seed %d, after %d shrinks: %v\noriginal: %v\nshrunk:   %v",
+		f.Iteration, f.Seed, f.Shrinks, f.Err, f.Original, f.Shrunk)
+}
+
+// Check checks that prop holds for random values created by gen, using the
+// default configuration. The test fails if prop returns an error or panics.
+func Check[T any](t testing.TB, gen Gen[T], prop func(T) error) {
+	t.Helper()
+	CheckConfig(t, DefaultConfig(), gen, prop)
+}
+
+// CheckConfig checks that prop holds for random values created by gen, using
+// the provided configuration. The test fails if prop returns an error or
+// panics.
+func CheckConfig[T any](t testing.TB, cfg Config, gen Gen[T], prop func(T) error) {
+	t.Helper()
+	if err := Run(cfg, gen, prop); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Run checks that prop holds for random values created by gen, and returns a
+// *Failure if it does not. An error is also returned if the configuration is
+// not valid.
+func Run[T any](cfg Config, gen Gen[T], prop func(T) error) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if gen.Generate == nil {
+		return errors.New("generator has no Generate function")
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = random.NewRand().Int63()
+	}
+
+	for i := range cfg.Iterations {
+		iterSeed := seed + int64(i)
+		v := gen.Generate(random.NewSeededRand(iterSeed))
+		err := call(prop, v)
+		if err == nil {
+			continue
+		}
+		f := &Failure[T]{
+			Seed:      iterSeed,
+			Iteration: i + 1,
+			Original:  v,
+		}
+		f.Shrunk, f.Err, f.Shrinks = shrink(cfg.MaxShrinks, gen, prop, v, err)
+		return f
+	}
+	return nil
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Iterations < 1 {
+		return errors.New("iterations must be at least 1")
+	}
+	if cfg.MaxShrinks < 0 {
+		return errors.New("max shrinks must be 0 or greater")
+	}
+	return nil
+}
+
+// shrink repeatedly replaces the failing value v with the first smaller value
+// for which prop also fails, until no smaller value fails or prop has been
+// called maxCalls times.
+func shrink[T any](maxCalls int, gen Gen[T], prop func(T) error, v T, err error) (T, error, int) {
+	if gen.Shrink == nil {
+		return v, err, 0
+	}
+	var calls, shrinks int
+	for calls < maxCalls {
+		shrunk := false
+		for _, smaller := range gen.Shrink(v) {
+			if calls == maxCalls {
+				break
+			}
+			calls++
+			if smallerErr := call(prop, smaller); smallerErr != nil {
+				v, err = smaller, smallerErr
+				shrinks++
+				shrunk = true
+				break
+			}
+		}
+		if !shrunk {
+			break
+		}
+	}
+	return v, err, shrinks
+}
+
+// call calls prop and returns a panic as an error.
+func call[T any](prop func(T) error, v T) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return prop(v)
+}
@@ -0,0 +1,201 @@
+package prop_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-test/prop"
+	"github.com/ipfs/go-test/random/files"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	var calls int
+	prop.Check(t, prop.Bytes(64), func(b []byte) error {
+		calls++
+		if len(b) > 64 {
+			return errors.New("too long")
+		}
+		return nil
+	})
+	require.Equal(t, prop.DefaultConfig().Iterations, calls)
+
+	prop.Check(t, prop.Cids(8), func(cids []cid.Cid) error {
+		for _, c := range cids {
+			if !c.Defined() {
+				return errors.New("undefined cid")
+			}
+		}
+		return nil
+	})
+	prop.Check(t, prop.Peers(4), func(peers []peer.ID) error {
+		for _, p := range peers {
+			if err := p.Validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	prop.Check(t, prop.Multiaddrs(4), func(addrs []multiaddr.Multiaddr) error {
+		for _, addr := range addrs {
+			if _, err := multiaddr.NewMultiaddrBytes(addr.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestShrinkBytes(t *testing.T) {
+	cfg := prop.DefaultConfig()
+	err := prop.Run(cfg, prop.Bytes(256), func(b []byte) error {
+		if bytes.IndexByte(b, 0xff) != -1 {
+			return errors.New("contains 0xff")
+		}
+		return nil
+	})
+	var f *prop.Failure[[]byte]
+	require.ErrorAs(t, err, &f)
+	require.Equal(t, []byte{0xff}, f.Shrunk)
+	require.Contains(t, f.Original, byte(0xff))
+	require.Greater(t, f.Shrinks, 0)
+	require.Contains(t, err.Error(), fmt.Sprintf("seed %d", f.Seed))
+
+	// The reported seed reproduces the failure on the first iteration.
+	cfg.Seed = f.Seed
+	err = prop.Run(cfg, prop.Bytes(256), func(b []byte) error {
+		if bytes.IndexByte(b, 0xff) != -1 {
+			return errors.New("contains 0xff")
+		}
+		return nil
+	})
+	var again *prop.Failure[[]byte]
+	require.ErrorAs(t, err, &again)
+	require.Equal(t, 1, again.Iteration)
+	require.Equal(t, f.Original, again.Original)
+}
+
+func TestShrinkSlice(t *testing.T) {
+	err := prop.Run(prop.DefaultConfig(), prop.Cids(16), func(cids []cid.Cid) error {
+		if len(cids) >= 3 {
+			return errors.New("too many cids")
+		}
+		return nil
+	})
+	var f *prop.Failure[[]cid.Cid]
+	require.ErrorAs(t, err, &f)
+	require.Len(t, f.Shrunk, 3)
+}
+
+func TestShrinkInt(t *testing.T) {
+	err := prop.Run(prop.DefaultConfig(), prop.Int(-1000, 1000), func(n int) error {
+		if n > 100 || n < -100 {
+			return errors.New("out of range")
+		}
+		return nil
+	})
+	var f *prop.Failure[int]
+	require.ErrorAs(t, err, &f)
+	require.Contains(t, []int{101, -101}, f.Shrunk)
+}
+
+func TestShrinkFileTree(t *testing.T) {
+	limit := files.DefaultConfig()
+	limit.Depth = 3
+	limit.Dirs = 3
+	limit.Files = 5
+	limit.FileSize = 1000
+
+	cfg := prop.DefaultConfig()
+	cfg.Iterations = 20
+	err := prop.Run(cfg, prop.FileTree(limit), func(fc files.Config) error {
+		dir := t.TempDir()
+		if err := files.Create(fc, dir); err != nil {
+			return err
+		}
+		var found bool
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if filepath.Dir(path) != dir {
+				found = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if found {
+			return errors.New("file in subdirectory")
+		}
+		return nil
+	})
+	var f *prop.Failure[files.Config]
+	require.ErrorAs(t, err, &f)
+	require.Equal(t, 2, f.Shrunk.Depth)
+	require.Equal(t, 1, f.Shrunk.Dirs)
+	require.Equal(t, 1, f.Shrunk.Files)
+	require.Zero(t, f.Shrunk.FileSize)
+	require.Equal(t, f.Original.Seed, f.Shrunk.Seed)
+}
+
+func TestIntRange(t *testing.T) {
+	for _, r := range [][2]int{{math.MinInt, math.MaxInt}, {math.MinInt, 0}, {-1, math.MaxInt}, {7, 7}} {
+		prop.Check(t, prop.Int(r[0], r[1]), func(n int) error {
+			if n < r[0] || n > r[1] {
+				return fmt.Errorf("%d is not between %d and %d", n, r[0], r[1])
+			}
+			return nil
+		})
+	}
+	require.Panics(t, func() { prop.Int(1, 0) })
+}
+
+func TestFileTreeZeroLimit(t *testing.T) {
+	limit := files.DefaultConfig()
+	limit.Depth = 0
+	limit.Dirs = 0
+	limit.Files = 0
+	limit.FileSize = 0
+	prop.Check(t, prop.FileTree(limit), func(fc files.Config) error {
+		if fc.Depth != 1 || fc.Dirs != 0 || fc.Files != 0 || fc.FileSize != 0 {
+			return fmt.Errorf("config exceeds limit: %+v", fc)
+		}
+		return files.Create(fc, t.TempDir())
+	})
+
+	limit.Depth = 3
+	prop.Check(t, prop.FileTree(limit), func(fc files.Config) error {
+		return files.Create(fc, t.TempDir())
+	})
+
+	limit.Files = -1
+	require.Panics(t, func() { prop.FileTree(limit) })
+}
+
+func TestPanic(t *testing.T) {
+	err := prop.Run(prop.DefaultConfig(), prop.Bytes(16), func(b []byte) error {
+		_ = b[4]
+		return nil
+	})
+	var f *prop.Failure[[]byte]
+	require.ErrorAs(t, err, &f)
+	require.Empty(t, f.Shrunk)
+	require.ErrorContains(t, f.Err, "panic")
+}
+
+func TestRunInvalid(t *testing.T) {
+	cfg := prop.DefaultConfig()
+	cfg.Iterations = 0
+	require.Error(t, prop.Run(cfg, prop.Bytes(1), func([]byte) error { return nil }))
+	require.Error(t, prop.Run(prop.DefaultConfig(), prop.Gen[int]{}, func(int) error { return nil }))
+}
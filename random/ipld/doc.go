@@ -0,0 +1,8 @@
+// Package ipld provides functionality for creating random IPLD data model
+// nodes, and their dag-cbor and dag-json encodings. This is useful for testing
+// codecs and anything else that consumes arbitrary IPLD data. Nodes that
+// conform to a type in an IPLD schema can be created for testing code that
+// consumes structured data. Invalid encodings can also be created for negative
+// tests. Random results are reproducible by reusing the same seed. Random
+// values are not cryptographically secure.
+package ipld
@@ -0,0 +1,560 @@
+package ipld
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
+// Config contains settings for creating random IPLD nodes.
+type Config struct {
+	// MaxDepth is the maximum depth of nested maps and lists, including the
+	// root node. A MaxDepth of 1 creates only scalar nodes.
+	MaxDepth int
+	// MaxNodes is the maximum number of nodes in each created value, including
+	// the root node and all map and list entries.
+	MaxNodes int
+	// MaxLength is the maximum number of entries in a map or list.
+	MaxLength int
+	// MaxStringSize is the maximum number of characters in a string or map
+	// key. Maps have fewer entries if there are too few possible keys of this
+	// size, such as when MaxStringSize is 0.
+	MaxStringSize int
+	// MaxBytesSize is the maximum number of bytes in a bytes node.
+	MaxBytesSize int
+	// IntegralFloats allows floats that have integer values, such as 1.0 and
+	// -0.0. These are valid, but are encoded as integers by some dag-json
+	// encoders, and so do not round-trip through dag-json.
+	IntegralFloats bool
+	// Weights maps each data model kind to its relative weight. A kind with a
+	// greater weight is chosen more often, and a kind with no weight is never
+	// chosen. Nodes at the maximum depth, or when MaxNodes is reached, are
+	// chosen from the scalar kinds only.
+	Weights map[datamodel.Kind]int
+	// Seed sets the seed for the random number generator when set to a
+	// non-zero value.
+	Seed int64
+}
+
+// Fault is a kind of invalid encoding created by InvalidDagCBOR and
+// InvalidDagJSON.
+type Fault int
+
+const (
+	// UnsortedKeys encodes a map whose keys are not in canonical order.
+	UnsortedKeys Fault = iota
+	// DuplicateKeys encodes a map that contains the same key twice.
+	DuplicateKeys
+	// IndefiniteLength encodes a list or map with an indefinite length. This
+	// is only applicable to dag-cbor.
+	IndefiniteLength
+	// NonMinimalInt encodes an integer using more bytes than necessary. This
+	// is only applicable to dag-cbor.
+	NonMinimalInt
+	// NaNFloat encodes a NaN float value.
+	NaNFloat
+)
+
+var (
+	allKinds = []datamodel.Kind{
+		datamodel.Kind_Map, datamodel.Kind_List, datamodel.Kind_Null, datamodel.Kind_Bool,
+		datamodel.Kind_Int, datamodel.Kind_Float, datamodel.Kind_String, datamodel.Kind_Bytes,
+		datamodel.Kind_Link,
+	}
+
+	edgeInts = []int64{
+		0, 1, -1, 23, 24, -24, -25, 255, 256, -256, -257, 65535, 65536,
+		math.MaxUint32, math.MaxUint32 + 1, math.MaxInt64, math.MinInt64, math.MinInt64 + 1,
+	}
+
+	edgeFloats = []float64{
+		0, math.Copysign(0, -1), 0.5, -1.5, 1, 1 << 53, 1e300, -1e-300,
+		math.MaxFloat64, -math.MaxFloat64, math.SmallestNonzeroFloat64,
+	}
+
+	// edgeRunes are characters that are often handled incorrectly, such as
+	// control characters, characters that must be escaped in JSON, combining
+	// and zero-width characters, bidirectional overrides, noncharacters, and
+	// characters outside the basic multilingual plane.
+	edgeRunes = []rune{
+		0, '\t', '\n', '\r', '"', '\\', '/', 0x7f, 0x80, 0xe9, 0x301, 0x200b, 0x200d,
+		0x2028, 0x2029, 0x202e, 0xfeff, 0xfffd, 0xffff, 0x4e2d, 0x1f600, 0x1f469, 0x10ffff,
+	}
+
+	linkCodecs = []multicodec.Code{multicodec.DagCbor, multicodec.DagJson, multicodec.DagPb, multicodec.Raw}
+)
+
+// maxKeyAttempts is the number of times in a row that a generated map key can
+// be the same as an earlier key before no more keys are generated.
+const maxKeyAttempts = 64
+
+// DefaultConfig returns default settings for creating random IPLD nodes.
+func DefaultConfig() Config {
+	return Config{
+		MaxDepth:      4,
+		MaxNodes:      64,
+		MaxLength:     8,
+		MaxStringSize: 16,
+		MaxBytesSize:  32,
+		Weights:       DefaultWeights(),
+	}
+}
+
+// DefaultWeights returns the default relative weights of each data model kind,
+// which is an equal weight for every kind.
+func DefaultWeights() map[datamodel.Kind]int {
+	weights := make(map[datamodel.Kind]int, len(allKinds))
+	for _, kind := range allKinds {
+		weights[kind] = 1
+	}
+	return weights
+}
+
+// Node creates a random IPLD node according to the provided configuration.
+func Node(cfg Config) (datamodel.Node, error) {
+	nodes, err := Nodes(1, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// Nodes creates n random IPLD nodes according to the provided configuration.
+func Nodes(n int, cfg Config) ([]datamodel.Node, error) {
+	g, err := newGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]datamodel.Node, n)
+	for i := range n {
+		if nodes[i], err = g.node(); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// EncodeDagCBOR returns the dag-cbor encoding of the node.
+func EncodeDagCBOR(node datamodel.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(node, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeDagJSON returns the dag-json encoding of the node.
+func EncodeDagJSON(node datamodel.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := dagjson.Encode(node, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// InvalidDagCBOR creates data that resembles dag-cbor, containing random
+// nodes, but is not valid dag-cbor because of the specified fault.
+func InvalidDagCBOR(cfg Config, fault Fault) ([]byte, error) {
+	g, err := newGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	values, err := g.encodedValues(EncodeDagCBOR)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch fault {
+	case UnsortedKeys, DuplicateKeys:
+		keys, err := g.faultKeys(fault, len(values), func(a, b string) int {
+			// Canonical dag-cbor order is shortest key first, then bytewise.
+			if len(a) != len(b) {
+				return len(a) - len(b)
+			}
+			return bytes.Compare([]byte(a), []byte(b))
+		})
+		if err != nil {
+			return nil, err
+		}
+		values = values[:len(keys)]
+		buf.Write(cborHead(5, uint64(len(values))))
+		for i, value := range values {
+			buf.Write(cborHead(3, uint64(len(keys[i]))))
+			buf.WriteString(keys[i])
+			buf.Write(value)
+		}
+	case IndefiniteLength:
+		if g.rng.Intn(2) == 0 {
+			buf.WriteByte(0x9f)
+			for _, value := range values {
+				buf.Write(value)
+			}
+		} else {
+			buf.WriteByte(0xbf)
+			keys, err := g.faultKeys(UnsortedKeys, len(values), nil)
+			if err != nil {
+				return nil, err
+			}
+			values = values[:len(keys)]
+			for i, value := range values {
+				buf.Write(cborHead(3, uint64(len(keys[i]))))
+				buf.WriteString(keys[i])
+				buf.Write(value)
+			}
+		}
+		buf.WriteByte(0xff)
+	case NonMinimalInt, NaNFloat:
+		buf.Write(cborHead(4, uint64(len(values)+1)))
+		i := g.rng.Intn(len(values) + 1)
+		for _, value := range values[:i] {
+			buf.Write(value)
+		}
+		if fault == NaNFloat {
+			buf.Write([]byte{0xfb, 0x7f, 0xf8, 0, 0, 0, 0, 0, 0})
+		} else {
+			// An 8 byte unsigned integer with a value that fits in 1 byte.
+			buf.Write([]byte{0x1b, 0, 0, 0, 0, 0, 0, 0, byte(g.rng.Intn(256))})
+		}
+		for _, value := range values[i:] {
+			buf.Write(value)
+		}
+	default:
+		return nil, fmt.Errorf("unknown fault %d", fault)
+	}
+	return buf.Bytes(), nil
+}
+
+// InvalidDagJSON creates data that resembles dag-json, containing random
+// nodes, but is not valid dag-json because of the specified fault. Only the
+// UnsortedKeys, DuplicateKeys and NaNFloat faults are applicable to dag-json.
+func InvalidDagJSON(cfg Config, fault Fault) ([]byte, error) {
+	g, err := newGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	values, err := g.encodedValues(EncodeDagJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch fault {
+	case UnsortedKeys, DuplicateKeys:
+		// Canonical dag-json order is bytewise.
+		keys, err := g.faultKeys(fault, len(values), func(a, b string) int {
+			return bytes.Compare([]byte(a), []byte(b))
+		})
+		if err != nil {
+			return nil, err
+		}
+		values = values[:len(keys)]
+		buf.WriteByte('{')
+		for i, value := range values {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			key, err := EncodeDagJSON(basicnode.NewString(keys[i]))
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+	case NaNFloat:
+		i := g.rng.Intn(len(values) + 1)
+		values = slices.Insert(values, i, []byte("NaN"))
+		buf.WriteByte('[')
+		buf.Write(bytes.Join(values, []byte{','}))
+		buf.WriteByte(']')
+	case IndefiniteLength, NonMinimalInt:
+		return nil, errors.New("fault is not applicable to dag-json")
+	default:
+		return nil, fmt.Errorf("unknown fault %d", fault)
+	}
+	return buf.Bytes(), nil
+}
+
+type generator struct {
+	cfg    Config
+	rng    *rand.Rand
+	budget int
+
+	kinds       []datamodel.Kind
+	cumul       []int
+	scalars     []datamodel.Kind
+	scalarCumul []int
+}
+
+func newGenerator(cfg Config) (*generator, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	g := &generator{cfg: cfg}
+	if cfg.Seed == 0 {
+		g.rng = random.NewRand()
+	} else {
+		g.rng = random.NewSeededRand(cfg.Seed)
+	}
+
+	var total, scalarTotal int
+	for _, kind := range allKinds {
+		weight := cfg.Weights[kind]
+		if weight == 0 {
+			continue
+		}
+		total += weight
+		g.kinds = append(g.kinds, kind)
+		g.cumul = append(g.cumul, total)
+		if kind != datamodel.Kind_Map && kind != datamodel.Kind_List {
+			scalarTotal += weight
+			g.scalars = append(g.scalars, kind)
+			g.scalarCumul = append(g.scalarCumul, scalarTotal)
+		}
+	}
+	if len(g.scalars) == 0 {
+		// Collections must end with something.
+		g.scalars = []datamodel.Kind{datamodel.Kind_Null}
+		g.scalarCumul = []int{1}
+	}
+	return g, nil
+}
+
+func (cfg *Config) validate() error {
+	if cfg.MaxDepth < 1 {
+		return errors.New("max depth must be at least 1")
+	}
+	if cfg.MaxNodes < 1 {
+		return errors.New("max nodes must be at least 1")
+	}
+	if cfg.MaxLength < 0 || cfg.MaxStringSize < 0 || cfg.MaxBytesSize < 0 {
+		return errors.New("max length and sizes must be 0 or greater")
+	}
+	var total int
+	for kind, weight := range cfg.Weights {
+		if !slices.Contains(allKinds, kind) {
+			return fmt.Errorf("invalid kind %s", kind)
+		}
+		if weight < 0 {
+			return errors.New("weight must be 0 or greater")
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errors.New("at least one weight must be greater than 0")
+	}
+	return nil
+}
+
+func (g *generator) node() (datamodel.Node, error) {
+	g.budget = g.cfg.MaxNodes - 1
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := g.assemble(nb, 1); err != nil {
+		return nil, err
+	}
+	return nb.Build(), nil
+}
+
+// encodedValues returns between 2 and MaxLength+2 encoded random nodes.
+func (g *generator) encodedValues(encode func(datamodel.Node) ([]byte, error)) ([][]byte, error) {
+	values := make([][]byte, 2+g.rng.Intn(g.cfg.MaxLength+1))
+	for i := range values {
+		node, err := g.node()
+		if err != nil {
+			return nil, err
+		}
+		if values[i], err = encode(node); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// faultKeys returns up to n map keys that are ordered according to the fault.
+// If compare is nil, the keys are unique and in random order. Fewer than n keys
+// are returned if MaxStringSize is too small for n unique keys, and an error
+// is returned if there are too few keys to order according to the fault.
+func (g *generator) faultKeys(fault Fault, n int, compare func(a, b string) int) ([]string, error) {
+	keys := g.uniqueKeys(n)
+	if compare == nil {
+		return keys, nil
+	}
+	if len(keys) < 2 {
+		return nil, errors.New("max string size is too small for unique map keys")
+	}
+	slices.SortFunc(keys, compare)
+	if fault == DuplicateKeys {
+		i := g.rng.Intn(len(keys) - 1)
+		keys[i+1] = keys[i]
+	} else {
+		slices.Reverse(keys)
+	}
+	return keys, nil
+}
+
+// uniqueKeys returns up to n unique random map keys. Fewer keys are returned if
+// no new key is found after maxKeyAttempts attempts, as there may be fewer than
+// n possible keys when MaxStringSize is small.
+func (g *generator) uniqueKeys(n int) []string {
+	keys := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	for attempts := 0; len(keys) < n && attempts < maxKeyAttempts; attempts++ {
+		key := g.randomString()
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+		attempts = 0
+	}
+	return keys
+}
+
+func (g *generator) pickKind(depth int) datamodel.Kind {
+	kinds, cumul := g.kinds, g.cumul
+	if depth >= g.cfg.MaxDepth || g.budget == 0 {
+		kinds, cumul = g.scalars, g.scalarCumul
+	}
+	n := g.rng.Intn(cumul[len(cumul)-1])
+	i, _ := slices.BinarySearch(cumul, n+1)
+	return kinds[i]
+}
+
+func (g *generator) assemble(na datamodel.NodeAssembler, depth int) error {
+	switch g.pickKind(depth) {
+	case datamodel.Kind_Map:
+		keys := g.uniqueKeys(g.rng.Intn(min(g.cfg.MaxLength, g.budget) + 1))
+		g.budget -= len(keys)
+		ma, err := na.BeginMap(int64(len(keys)))
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err = ma.AssembleKey().AssignString(key); err != nil {
+				return err
+			}
+			if err = g.assemble(ma.AssembleValue(), depth+1); err != nil {
+				return err
+			}
+		}
+		return ma.Finish()
+	case datamodel.Kind_List:
+		n := g.rng.Intn(min(g.cfg.MaxLength, g.budget) + 1)
+		g.budget -= n
+		la, err := na.BeginList(int64(n))
+		if err != nil {
+			return err
+		}
+		for range n {
+			if err = g.assemble(la.AssembleValue(), depth+1); err != nil {
+				return err
+			}
+		}
+		return la.Finish()
+	case datamodel.Kind_Null:
+		return na.AssignNull()
+	case datamodel.Kind_Bool:
+		return na.AssignBool(g.rng.Intn(2) == 0)
+	case datamodel.Kind_Int:
+		return na.AssignInt(g.randomInt())
+	case datamodel.Kind_Float:
+		return na.AssignFloat(g.randomFloat())
+	case datamodel.Kind_String:
+		return na.AssignString(g.randomString())
+	case datamodel.Kind_Bytes:
+		b := make([]byte, g.rng.Intn(g.cfg.MaxBytesSize+1))
+		g.rng.Read(b)
+		return na.AssignBytes(b)
+	case datamodel.Kind_Link:
+		return na.AssignLink(cidlink.Link{Cid: g.randomCid()})
+	}
+	return nil
+}
+
+func (g *generator) randomInt() int64 {
+	if g.rng.Intn(4) == 0 {
+		return edgeInts[g.rng.Intn(len(edgeInts))]
+	}
+	n := g.rng.Int63() >> g.rng.Intn(63)
+	if g.rng.Intn(2) == 0 {
+		n = -n
+	}
+	return n
+}
+
+// randomFloat returns a random finite float. NaN and infinity cannot be
+// encoded in dag-cbor or dag-json.
+func (g *generator) randomFloat() float64 {
+	for {
+		var f float64
+		if g.rng.Intn(4) == 0 {
+			f = edgeFloats[g.rng.Intn(len(edgeFloats))]
+		} else {
+			f = g.rng.NormFloat64() * math.Pow10(g.rng.Intn(41)-20)
+		}
+		if g.cfg.IntegralFloats || f != math.Trunc(f) {
+			return f
+		}
+	}
+}
+
+func (g *generator) randomString() string {
+	runes := make([]rune, g.rng.Intn(g.cfg.MaxStringSize+1))
+	for i := range runes {
+		switch g.rng.Intn(4) {
+		case 0:
+			runes[i] = edgeRunes[g.rng.Intn(len(edgeRunes))]
+		case 1:
+			// Any valid character, excluding surrogates.
+			r := rune(g.rng.Intn(0x10ffff - 0x800))
+			if r >= 0xd800 {
+				r += 0x800
+			}
+			runes[i] = r
+		default:
+			runes[i] = rune(' ' + g.rng.Intn('~'-' '+1))
+		}
+	}
+	return string(runes)
+}
+
+func (g *generator) randomCid() cid.Cid {
+	var b [32]byte
+	g.rng.Read(b[:])
+	h, err := multihash.Encode(b[:], multihash.SHA2_256)
+	if err != nil {
+		panic(err)
+	}
+	return cid.NewCidV1(uint64(linkCodecs[g.rng.Intn(len(linkCodecs))]), h)
+}
+
+// cborHead returns the minimal CBOR encoding of a major type and argument.
+func cborHead(major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return []byte{major | byte(n)}
+	case n <= math.MaxUint8:
+		return []byte{major | 24, byte(n)}
+	case n <= math.MaxUint16:
+		return []byte{major | 25, byte(n >> 8), byte(n)}
+	case n <= math.MaxUint32:
+		return []byte{major | 26, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	return []byte{major | 27, byte(n >> 56), byte(n >> 48), byte(n >> 40), byte(n >> 32), byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
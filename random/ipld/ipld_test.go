@@ -0,0 +1,182 @@
+package ipld_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/ipfs/go-test/random/ipld"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/stretchr/testify/require"
+)
+
+func countNodes(n datamodel.Node, depth int) (count, maxDepth int) {
+	count, maxDepth = 1, depth
+	it := func(v datamodel.Node) {
+		c, d := countNodes(v, depth+1)
+		count += c
+		maxDepth = max(maxDepth, d)
+	}
+	switch n.Kind() {
+	case datamodel.Kind_Map:
+		for mi := n.MapIterator(); !mi.Done(); {
+			_, v, _ := mi.Next()
+			it(v)
+		}
+	case datamodel.Kind_List:
+		for li := n.ListIterator(); !li.Done(); {
+			_, v, _ := li.Next()
+			it(v)
+		}
+	}
+	return count, maxDepth
+}
+
+func TestNodesRoundTrip(t *testing.T) {
+	cfg := ipld.DefaultConfig()
+	nodes, err := ipld.Nodes(200, cfg)
+	require.NoError(t, err)
+
+	for _, n := range nodes {
+		count, depth := countNodes(n, 1)
+		require.LessOrEqual(t, count, cfg.MaxNodes)
+		require.LessOrEqual(t, depth, cfg.MaxDepth)
+
+		enc, err := ipld.EncodeDagCBOR(n)
+		require.NoError(t, err)
+		nb := basicnode.Prototype.Any.NewBuilder()
+		require.NoError(t, dagcbor.Decode(nb, bytes.NewReader(enc)))
+		reenc, err := ipld.EncodeDagCBOR(nb.Build())
+		require.NoError(t, err)
+		require.Equal(t, enc, reenc, "dag-cbor round trip mismatch")
+
+		enc, err = ipld.EncodeDagJSON(n)
+		require.NoError(t, err)
+		nb = basicnode.Prototype.Any.NewBuilder()
+		require.NoError(t, dagjson.Decode(nb, bytes.NewReader(enc)))
+		reenc, err = ipld.EncodeDagJSON(nb.Build())
+		require.NoError(t, err)
+		require.Equal(t, string(enc), string(reenc), "dag-json round trip mismatch")
+	}
+}
+
+func TestNodesConfig(t *testing.T) {
+	cfg := ipld.DefaultConfig()
+	cfg.Seed = 1234
+	n1, err := ipld.Node(cfg)
+	require.NoError(t, err)
+	n2, err := ipld.Node(cfg)
+	require.NoError(t, err)
+	require.True(t, datamodel.DeepEqual(n1, n2))
+
+	cfg.Weights = map[datamodel.Kind]int{datamodel.Kind_Int: 1}
+	nodes, err := ipld.Nodes(20, cfg)
+	require.NoError(t, err)
+	for _, n := range nodes {
+		require.Equal(t, datamodel.Kind_Int, n.Kind())
+	}
+
+	cfg.Weights = map[datamodel.Kind]int{datamodel.Kind_List: 1}
+	cfg.MaxDepth = 3
+	nodes, err = ipld.Nodes(20, cfg)
+	require.NoError(t, err)
+	for _, n := range nodes {
+		require.Equal(t, datamodel.Kind_List, n.Kind())
+		_, depth := countNodes(n, 1)
+		require.LessOrEqual(t, depth, cfg.MaxDepth)
+	}
+
+	cfg = ipld.DefaultConfig()
+	cfg.Weights = map[datamodel.Kind]int{datamodel.Kind_Float: 1}
+	cfg.IntegralFloats = true
+	nodes, err = ipld.Nodes(200, cfg)
+	require.NoError(t, err)
+	var integral bool
+	for _, n := range nodes {
+		f, err := n.AsFloat()
+		require.NoError(t, err)
+		require.False(t, math.IsNaN(f) || math.IsInf(f, 0))
+		if f == math.Trunc(f) {
+			integral = true
+		}
+		enc, err := ipld.EncodeDagCBOR(n)
+		require.NoError(t, err)
+		nb := basicnode.Prototype.Any.NewBuilder()
+		require.NoError(t, dagcbor.Decode(nb, bytes.NewReader(enc)))
+		require.True(t, datamodel.DeepEqual(n, nb.Build()))
+	}
+	require.True(t, integral, "expected integral floats")
+
+	cfg = ipld.DefaultConfig()
+	cfg.Weights = map[datamodel.Kind]int{datamodel.Kind_Map: 1, datamodel.Kind_Null: 1}
+	cfg.MaxStringSize = 0
+	nodes, err = ipld.Nodes(20, cfg)
+	require.NoError(t, err)
+	for _, n := range nodes {
+		require.LessOrEqual(t, n.Length(), int64(1))
+	}
+
+	cfg = ipld.DefaultConfig()
+	cfg.MaxDepth = 0
+	_, err = ipld.Node(cfg)
+	require.Error(t, err)
+
+	cfg = ipld.DefaultConfig()
+	cfg.Weights = map[datamodel.Kind]int{datamodel.Kind_Invalid: 1}
+	_, err = ipld.Node(cfg)
+	require.Error(t, err)
+}
+
+// requireInvalid checks that data is either rejected by the decoder, does not
+// re-encode to the same bytes, meaning it is not canonical, or contains a NaN.
+func requireInvalid(t *testing.T, data []byte, decode func(datamodel.NodeAssembler, *bytes.Reader) error, encode func(datamodel.Node) ([]byte, error)) {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := decode(nb, bytes.NewReader(data)); err != nil {
+		return
+	}
+	n := nb.Build()
+	if n.Kind() == datamodel.Kind_List {
+		for li := n.ListIterator(); !li.Done(); {
+			_, v, _ := li.Next()
+			if f, err := v.AsFloat(); err == nil && math.IsNaN(f) {
+				return
+			}
+		}
+	}
+	reenc, err := encode(n)
+	require.NoError(t, err)
+	require.NotEqual(t, data, reenc, "invalid data is canonical")
+}
+
+func TestInvalidDagCBOR(t *testing.T) {
+	cfg := ipld.DefaultConfig()
+	decode := func(na datamodel.NodeAssembler, r *bytes.Reader) error { return dagcbor.Decode(na, r) }
+	for _, fault := range []ipld.Fault{ipld.UnsortedKeys, ipld.DuplicateKeys, ipld.IndefiniteLength, ipld.NonMinimalInt, ipld.NaNFloat} {
+		for range 20 {
+			data, err := ipld.InvalidDagCBOR(cfg, fault)
+			require.NoError(t, err)
+			requireInvalid(t, data, decode, ipld.EncodeDagCBOR)
+		}
+	}
+}
+
+func TestInvalidDagJSON(t *testing.T) {
+	cfg := ipld.DefaultConfig()
+	decode := func(na datamodel.NodeAssembler, r *bytes.Reader) error { return dagjson.Decode(na, r) }
+	for _, fault := range []ipld.Fault{ipld.UnsortedKeys, ipld.DuplicateKeys, ipld.NaNFloat} {
+		for range 20 {
+			data, err := ipld.InvalidDagJSON(cfg, fault)
+			require.NoError(t, err)
+			requireInvalid(t, data, decode, ipld.EncodeDagJSON)
+		}
+	}
+	_, err := ipld.InvalidDagJSON(cfg, ipld.IndefiniteLength)
+	require.Error(t, err)
+
+	cfg.MaxStringSize = 0
+	_, err = ipld.InvalidDagJSON(cfg, ipld.UnsortedKeys)
+	require.Error(t, err)
+}
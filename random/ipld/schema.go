@@ -0,0 +1,342 @@
+package ipld
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	schemadmt "github.com/ipld/go-ipld-prime/schema/dmt"
+)
+
+// SchemaNode returns a random node that conforms to the named type of an IPLD
+// schema. The returned node is in the representation form of the type, so it
+// can be encoded directly, and decodes with the schema type's representation
+// prototype.
+//
+// The settings in cfg limit the size of the node as they do for Node. Values
+// of Any type are created as by Node, and Link values are random CIDs. The
+// Weights setting is only used for values of Any type. Optional fields are
+// omitted, nullable values are null, and empty lists and maps are created at
+// random, and always when MaxDepth or MaxNodes is reached, so that values of
+// recursive types end.
+//
+// The struct representations map, tuple, listpairs and stringjoin, and the
+// union representations keyed, kinded and stringprefix, are supported.
+func SchemaNode(sch *schemadmt.Schema, typeName string, cfg Config) (datamodel.Node, error) {
+	nodes, err := SchemaNodes(1, sch, typeName, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// SchemaNodes returns n random nodes that conform to the named type of an IPLD
+// schema. See SchemaNode.
+func SchemaNodes(n int, sch *schemadmt.Schema, typeName string, cfg Config) ([]datamodel.Node, error) {
+	ts := new(schema.TypeSystem)
+	ts.Init()
+	if err := schemadmt.Compile(ts, sch); err != nil {
+		return nil, fmt.Errorf("cannot compile schema: %w", err)
+	}
+	typ := ts.TypeByName(typeName)
+	if typ == nil {
+		return nil, fmt.Errorf("type %q not found in schema", typeName)
+	}
+	g, err := newGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]datamodel.Node, n)
+	for i := range nodes {
+		g.budget = cfg.MaxNodes - 1
+		if nodes[i], err = g.typedNode(typ, 1, ""); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// typedNode returns the representation of a random value of a schema type.
+// Strings do not contain any of the characters in avoid, which keeps the
+// fields of stringjoin structs from containing the join delimiter.
+func (g *generator) typedNode(typ schema.Type, depth int, avoid string) (datamodel.Node, error) {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := g.assembleType(nb, typ, depth, avoid); err != nil {
+		return nil, err
+	}
+	return nb.Build(), nil
+}
+
+func (g *generator) assembleType(na datamodel.NodeAssembler, typ schema.Type, depth int, avoid string) error {
+	// A type that contains itself through fields that are neither optional
+	// nor nullable has no finite values.
+	if depth > g.cfg.MaxDepth+len(typ.TypeSystem().GetTypes()) {
+		return fmt.Errorf("cannot create %s value: schema is too deeply nested", typ.Name())
+	}
+	atLimit := depth >= g.cfg.MaxDepth || g.budget <= 0
+
+	switch typ := typ.(type) {
+	case *schema.TypeBool:
+		return na.AssignBool(g.rng.Intn(2) == 0)
+	case *schema.TypeInt:
+		return na.AssignInt(g.randomInt())
+	case *schema.TypeFloat:
+		return na.AssignFloat(g.randomFloat())
+	case *schema.TypeString:
+		return na.AssignString(g.randomStringAvoiding(avoid))
+	case *schema.TypeBytes:
+		b := make([]byte, g.rng.Intn(g.cfg.MaxBytesSize+1))
+		g.rng.Read(b)
+		return na.AssignBytes(b)
+	case *schema.TypeLink:
+		return na.AssignLink(cidlink.Link{Cid: g.randomCid()})
+	case *schema.TypeAny:
+		return g.assembleAny(na, depth)
+	case *schema.TypeEnum:
+		member := typ.Members()[g.rng.Intn(len(typ.Members()))]
+		switch stg := typ.RepresentationStrategy().(type) {
+		case schema.EnumRepresentation_Int:
+			return na.AssignInt(int64(stg[member]))
+		case schema.EnumRepresentation_String:
+			if value, ok := stg[member]; ok {
+				return na.AssignString(value)
+			}
+		}
+		return na.AssignString(member)
+	case *schema.TypeList:
+		n := g.length(atLimit)
+		la, err := na.BeginList(int64(n))
+		if err != nil {
+			return err
+		}
+		for range n {
+			if err = g.assembleMaybe(la.AssembleValue(), typ.ValueType(), typ.ValueIsNullable(), depth+1); err != nil {
+				return err
+			}
+		}
+		return la.Finish()
+	case *schema.TypeMap:
+		keys, err := g.mapKeys(typ.KeyType(), g.length(atLimit))
+		if err != nil {
+			return err
+		}
+		ma, err := na.BeginMap(int64(len(keys)))
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err = ma.AssembleKey().AssignString(key); err != nil {
+				return err
+			}
+			if err = g.assembleMaybe(ma.AssembleValue(), typ.ValueType(), typ.ValueIsNullable(), depth+1); err != nil {
+				return err
+			}
+		}
+		return ma.Finish()
+	case *schema.TypeStruct:
+		return g.assembleStruct(na, typ, depth, atLimit)
+	case *schema.TypeUnion:
+		return g.assembleUnion(na, typ, depth, atLimit)
+	}
+	return fmt.Errorf("unsupported schema type %s of kind %s", typ.Name(), typ.TypeKind())
+}
+
+func (g *generator) assembleStruct(na datamodel.NodeAssembler, typ *schema.TypeStruct, depth int, atLimit bool) error {
+	fields := typ.Fields()
+	switch stg := typ.RepresentationStrategy().(type) {
+	case schema.StructRepresentation_Map:
+		ma, err := na.BeginMap(int64(len(fields)))
+		if err != nil {
+			return err
+		}
+		for _, field := range fields {
+			if field.IsOptional() && (atLimit || g.rng.Intn(2) == 0) {
+				continue
+			}
+			if err = ma.AssembleKey().AssignString(stg.GetFieldKey(field)); err != nil {
+				return err
+			}
+			if err = g.assembleMaybe(ma.AssembleValue(), field.Type(), field.IsNullable(), depth+1); err != nil {
+				return err
+			}
+		}
+		return ma.Finish()
+	case schema.StructRepresentation_Tuple, schema.StructRepresentation_ListPairs:
+		_, pairs := stg.(schema.StructRepresentation_ListPairs)
+		// Only trailing optional fields can be omitted from a tuple.
+		n := len(fields)
+		for n > 0 && fields[n-1].IsOptional() && (atLimit || g.rng.Intn(2) == 0) {
+			n--
+		}
+		la, err := na.BeginList(int64(n))
+		if err != nil {
+			return err
+		}
+		for _, field := range fields[:n] {
+			va := la.AssembleValue()
+			var pa datamodel.ListAssembler
+			if pairs {
+				if pa, err = va.BeginList(2); err != nil {
+					return err
+				}
+				if err = pa.AssembleValue().AssignString(field.Name()); err != nil {
+					return err
+				}
+				va = pa.AssembleValue()
+			}
+			if err = g.assembleMaybe(va, field.Type(), field.IsNullable(), depth+1); err != nil {
+				return err
+			}
+			if pairs {
+				if err = pa.Finish(); err != nil {
+					return err
+				}
+			}
+		}
+		return la.Finish()
+	case schema.StructRepresentation_Stringjoin:
+		parts := make([]string, len(fields))
+		for i, field := range fields {
+			node, err := g.typedNode(field.Type(), depth+1, stg.GetDelim())
+			if err != nil {
+				return err
+			}
+			if parts[i], err = node.AsString(); err != nil {
+				return fmt.Errorf("stringjoin struct %s: field %s is not a string", typ.Name(), field.Name())
+			}
+		}
+		return na.AssignString(strings.Join(parts, stg.GetDelim()))
+	}
+	return fmt.Errorf("unsupported representation of struct %s", typ.Name())
+}
+
+func (g *generator) assembleUnion(na datamodel.NodeAssembler, typ *schema.TypeUnion, depth int, atLimit bool) error {
+	members := typ.Members()
+	member := members[g.rng.Intn(len(members))]
+	if atLimit {
+		// Prefer members that do not nest further.
+		for _, i := range g.rng.Perm(len(members)) {
+			if isScalarType(members[i]) {
+				member = members[i]
+				break
+			}
+		}
+	}
+
+	switch stg := typ.RepresentationStrategy().(type) {
+	case schema.UnionRepresentation_Keyed:
+		ma, err := na.BeginMap(1)
+		if err != nil {
+			return err
+		}
+		if err = ma.AssembleKey().AssignString(stg.GetDiscriminant(member)); err != nil {
+			return err
+		}
+		if err = g.assembleType(ma.AssembleValue(), member, depth+1, ""); err != nil {
+			return err
+		}
+		return ma.Finish()
+	case schema.UnionRepresentation_Kinded:
+		return g.assembleType(na, member, depth, "")
+	case schema.UnionRepresentation_Stringprefix:
+		node, err := g.typedNode(member, depth+1, "")
+		if err != nil {
+			return err
+		}
+		s, err := node.AsString()
+		if err != nil {
+			return fmt.Errorf("stringprefix union %s: member %s is not a string", typ.Name(), member.Name())
+		}
+		return na.AssignString(stg.GetDiscriminant(member) + stg.GetDelim() + s)
+	}
+	return fmt.Errorf("unsupported representation of union %s", typ.Name())
+}
+
+// assembleAny assembles a random value of Any type. The value is not null,
+// unless null is the only kind that can be created, since null values are for
+// nullable fields and many schema implementations do not accept them
+// elsewhere.
+func (g *generator) assembleAny(na datamodel.NodeAssembler, depth int) error {
+	var node datamodel.Node
+	for range 8 {
+		nb := basicnode.Prototype.Any.NewBuilder()
+		if err := g.assemble(nb, depth); err != nil {
+			return err
+		}
+		if node = nb.Build(); !node.IsNull() {
+			break
+		}
+	}
+	return datamodel.Copy(node, na)
+}
+
+// assembleMaybe assembles a random value of a schema type, or null if the value
+// is nullable.
+func (g *generator) assembleMaybe(na datamodel.NodeAssembler, typ schema.Type, nullable bool, depth int) error {
+	if nullable && (depth >= g.cfg.MaxDepth || g.rng.Intn(4) == 0) {
+		return na.AssignNull()
+	}
+	return g.assembleType(na, typ, depth, "")
+}
+
+// length returns a random length for a list or map, and takes it from the
+// node budget.
+func (g *generator) length(atLimit bool) int {
+	if atLimit {
+		return 0
+	}
+	n := g.rng.Intn(min(g.cfg.MaxLength, g.budget) + 1)
+	g.budget -= n
+	return n
+}
+
+// mapKeys returns up to n unique random keys for a map whose keys are of type
+// typ.
+func (g *generator) mapKeys(typ schema.Type, n int) ([]string, error) {
+	keys := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	// Give up on keys that keep repeating, such as those of a small enum.
+	for tries := 0; len(keys) < n && tries < 4*n; tries++ {
+		node, err := g.typedNode(typ, g.cfg.MaxDepth, "")
+		if err != nil {
+			return nil, err
+		}
+		key, err := node.AsString()
+		if err != nil {
+			return nil, fmt.Errorf("map key type %s is not a string", typ.Name())
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// randomStringAvoiding returns a random string that does not contain any of
+// the characters in avoid.
+func (g *generator) randomStringAvoiding(avoid string) string {
+	s := g.randomString()
+	if avoid == "" {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(avoid, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func isScalarType(typ schema.Type) bool {
+	switch typ.TypeKind() {
+	case schema.TypeKind_Map, schema.TypeKind_List, schema.TypeKind_Struct, schema.TypeKind_Union, schema.TypeKind_Any:
+		return false
+	}
+	return true
+}
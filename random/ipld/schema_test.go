@@ -0,0 +1,189 @@
+package ipld_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/go-test/random/ipld"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/ipld/go-ipld-prime/schema"
+	schemadmt "github.com/ipld/go-ipld-prime/schema/dmt"
+	schemadsl "github.com/ipld/go-ipld-prime/schema/dsl"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `
+type Advertisement struct {
+	PreviousID optional Link
+	Provider String
+	Addresses [String]
+	Signature Bytes
+	Entries Link
+	ContextID Bytes
+	Metadata Bytes
+	IsRm Bool
+	ExtendedProvider optional ExtendedProvider
+}
+
+type ExtendedProvider struct {
+	Providers [Provider]
+	Override Bool
+}
+
+type Provider struct {
+	ID String
+	Addresses [String]
+	Metadata Bytes
+	Signature Bytes
+} representation tuple
+
+type Message struct {
+	Kind Kind
+	Payload Payload
+	Headers {String:nullable Int}
+	Extra optional Any
+	Next nullable Message
+}
+
+type Kind enum {
+	| Want
+	| Have
+	| Block
+}
+
+type Payload union {
+	| Bytes bytes
+	| String string
+	| Ints list
+	| Pair map
+} representation kinded
+
+type Ints [Int]
+
+type Pair struct {
+	Left Float
+	Right Int
+}
+
+type Version struct {
+	Major String
+	Minor String
+} representation stringjoin {
+	join "."
+}
+`
+
+func compileTestSchema(t *testing.T) (*schemadmt.Schema, *schema.TypeSystem) {
+	sch, err := schemadsl.ParseBytes([]byte(testSchema))
+	require.NoError(t, err)
+	ts := new(schema.TypeSystem)
+	ts.Init()
+	require.NoError(t, schemadmt.Compile(ts, sch))
+	return sch, ts
+}
+
+// message is the Go type of the recursive Message schema type, which bindnode
+// cannot infer.
+type message struct {
+	Kind    string
+	Payload struct {
+		Bytes  *[]byte
+		String *string
+		Ints   *[]int64
+		Pair   *struct {
+			Left  float64
+			Right int64
+		}
+	}
+	Headers struct {
+		Keys   []string
+		Values map[string]*int64
+	}
+	Extra *datamodel.Node
+	Next  *message
+}
+
+func TestSchemaNodes(t *testing.T) {
+	sch, ts := compileTestSchema(t)
+	cfg := ipld.DefaultConfig()
+
+	for typeName, goType := range map[string]any{
+		"Advertisement": nil,
+		"Message":       (*message)(nil),
+		"Payload":       nil,
+		"Version":       nil,
+		"Kind":          nil,
+	} {
+		t.Run(typeName, func(t *testing.T) {
+			nodes, err := ipld.SchemaNodes(50, sch, typeName, cfg)
+			require.NoError(t, err)
+			require.Len(t, nodes, 50)
+
+			proto := bindnode.Prototype(goType, ts.TypeByName(typeName)).Representation()
+			for _, node := range nodes {
+				data, err := ipld.EncodeDagCBOR(node)
+				require.NoError(t, err)
+				// Decoding with the schema checks that the node conforms.
+				nb := proto.NewBuilder()
+				require.NoError(t, dagcbor.Decode(nb, bytes.NewReader(data)))
+			}
+		})
+	}
+}
+
+func TestSchemaNodeRecursive(t *testing.T) {
+	sch, _ := compileTestSchema(t)
+	cfg := ipld.DefaultConfig()
+	cfg.MaxDepth = 3
+
+	for range 50 {
+		node, err := ipld.SchemaNode(sch, "Message", cfg)
+		require.NoError(t, err)
+		next, err := node.LookupByString("Next")
+		require.NoError(t, err)
+		if next.IsNull() {
+			continue
+		}
+		next, err = next.LookupByString("Next")
+		require.NoError(t, err)
+		require.True(t, next.IsNull(), "nested message beyond max depth")
+	}
+}
+
+func TestSchemaNodeSeed(t *testing.T) {
+	sch, _ := compileTestSchema(t)
+	cfg := ipld.DefaultConfig()
+	cfg.Seed = 42
+
+	a, err := ipld.SchemaNodes(5, sch, "Advertisement", cfg)
+	require.NoError(t, err)
+	b, err := ipld.SchemaNodes(5, sch, "Advertisement", cfg)
+	require.NoError(t, err)
+	for i := range a {
+		encA, err := ipld.EncodeDagCBOR(a[i])
+		require.NoError(t, err)
+		encB, err := ipld.EncodeDagCBOR(b[i])
+		require.NoError(t, err)
+		require.Equal(t, encA, encB)
+	}
+}
+
+func TestSchemaNodeErrors(t *testing.T) {
+	sch, _ := compileTestSchema(t)
+
+	_, err := ipld.SchemaNode(sch, "Missing", ipld.DefaultConfig())
+	require.ErrorContains(t, err, "not found")
+
+	cfg := ipld.DefaultConfig()
+	cfg.MaxDepth = 0
+	_, err = ipld.SchemaNode(sch, "Advertisement", cfg)
+	require.Error(t, err)
+
+	// A struct that always contains itself has no finite values.
+	sch, err = schemadsl.ParseBytes([]byte(`type Loop struct { Next Loop }`))
+	require.NoError(t, err)
+	_, err = ipld.SchemaNode(sch, "Loop", ipld.DefaultConfig())
+	require.ErrorContains(t, err, "too deeply nested")
+}
@@ -0,0 +1,151 @@
+package random
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	ipns_pb "github.com/ipfs/boxo/ipns/pb"
+	"github.com/ipfs/boxo/path"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"google.golang.org/protobuf/proto"
+)
+
+// IPNSConfig contains settings for creating random IPNS records. Start from
+// DefaultIPNSConfig and change the settings as needed. The zero value is not
+// the default: its KeyType is crypto.RSA, which is slow, and its TTL is 0.
+type IPNSConfig struct {
+	// KeyType is the libp2p key type of the key that signs the records. The
+	// zero value is crypto.RSA.
+	KeyType int
+	// Bits is the RSA key size, see IdentityOfType.
+	Bits int
+	// Value is the path that each record points to. If nil, then each record
+	// points to a random /ipfs/ path.
+	Value path.Path
+	// EOL is the time at which the records' validity ends. If zero, then
+	// records are valid for ipns.DefaultRecordLifetime from now.
+	EOL time.Time
+	// TTL is the duration records may be cached for. A TTL of 0 is used as
+	// is, and is not replaced by ipns.DefaultRecordTTL.
+	TTL time.Duration
+	// Seq is the sequence number of every record. If zero, then each record
+	// gets a unique sequence number.
+	Seq uint64
+	// V1Compatible creates records with the deprecated V1 fields and
+	// signature in addition to the V2 signed data.
+	V1Compatible bool
+	// EmbedPublicKey, if not nil, overrides whether the public key is embedded
+	// in records. By default, the key is only embedded when it cannot be
+	// extracted from the IPNS name, as is the case for RSA and ECDSA keys.
+	EmbedPublicKey *bool
+
+	// Expired creates records whose EOL is in the past. EOL is ignored.
+	Expired bool
+	// FutureDated creates records whose EOL is years in the future, far beyond
+	// any normal record lifetime. EOL is ignored.
+	FutureDated bool
+	// BadSignature creates records whose signatures do not verify.
+	BadSignature bool
+}
+
+// IPNSRecord is an IPNS record along with the name it is published under and
+// the private key used to sign it.
+type IPNSRecord struct {
+	Name    ipns.Name
+	Record  *ipns.Record
+	PrivKey crypto.PrivKey
+}
+
+// DefaultIPNSConfig returns default settings for creating valid IPNS records
+// signed with Ed25519 keys.
+func DefaultIPNSConfig() IPNSConfig {
+	return IPNSConfig{
+		KeyType:      crypto.Ed25519,
+		TTL:          ipns.DefaultRecordTTL,
+		V1Compatible: true,
+	}
+}
+
+// IPNSRecords returns a slice of n IPNS records, each for a unique random
+// identity, created according to the provided configuration.
+func IPNSRecords(n int, cfg IPNSConfig) []IPNSRecord {
+	if err := cfg.validate(); err != nil {
+		panic(err)
+	}
+	rng := NewRand()
+	recs := make([]IPNSRecord, n)
+	for i := range n {
+		peerID, privKey, _ := IdentityOfType(cfg.KeyType, cfg.Bits)
+
+		value := cfg.Value
+		if value == nil {
+			value = path.FromCid(Cids(1)[0])
+		}
+		seq := cfg.Seq
+		if seq == 0 {
+			seq = SequenceNext()
+		}
+		eol := cfg.EOL
+		switch {
+		case cfg.Expired:
+			eol = time.Now().Add(-time.Duration(rng.Int63n(int64(ipns.DefaultRecordLifetime))) - time.Minute)
+		case cfg.FutureDated:
+			eol = time.Now().AddDate(100+rng.Intn(100), 0, 0)
+		case eol.IsZero():
+			eol = time.Now().Add(ipns.DefaultRecordLifetime)
+		}
+
+		opts := []ipns.Option{ipns.WithV1Compatibility(cfg.V1Compatible)}
+		if cfg.EmbedPublicKey != nil {
+			opts = append(opts, ipns.WithPublicKey(*cfg.EmbedPublicKey))
+		}
+		rec, err := ipns.NewRecord(privKey, value, seq, eol, cfg.TTL, opts...)
+		if err != nil {
+			panic(err)
+		}
+		if cfg.BadSignature {
+			rec = corruptIPNSSignature(rec)
+		}
+
+		recs[i] = IPNSRecord{
+			Name:    ipns.NameFromPeer(peerID),
+			Record:  rec,
+			PrivKey: privKey,
+		}
+	}
+	return recs
+}
+
+func (cfg *IPNSConfig) validate() error {
+	if cfg.Expired && cfg.FutureDated {
+		return errors.New("record cannot be both expired and future dated")
+	}
+	if cfg.TTL < 0 {
+		return errors.New("ttl must be 0 or greater")
+	}
+	return nil
+}
+
+// corruptIPNSSignature flips a bit in each of the record's signatures.
+func corruptIPNSSignature(rec *ipns.Record) *ipns.Record {
+	data, err := ipns.MarshalRecord(rec)
+	if err != nil {
+		panic(err)
+	}
+	var pb ipns_pb.IpnsRecord
+	if err = proto.Unmarshal(data, &pb); err != nil {
+		panic(err)
+	}
+	pb.SignatureV2[len(pb.SignatureV2)/2] ^= 0x01
+	if len(pb.SignatureV1) != 0 {
+		pb.SignatureV1[len(pb.SignatureV1)/2] ^= 0x01
+	}
+	if data, err = proto.Marshal(&pb); err != nil {
+		panic(err)
+	}
+	if rec, err = ipns.UnmarshalRecord(data); err != nil {
+		panic(err)
+	}
+	return rec
+}
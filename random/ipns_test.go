@@ -0,0 +1,97 @@
+package random_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-test/random"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPNSRecords(t *testing.T) {
+	cfg := random.DefaultIPNSConfig()
+	recs := random.IPNSRecords(3, cfg)
+	require.Len(t, recs, 3)
+	for _, r := range recs {
+		require.NoError(t, ipns.ValidateWithName(r.Record, r.Name))
+
+		ttl, err := r.Record.TTL()
+		require.NoError(t, err)
+		require.Equal(t, ipns.DefaultRecordTTL, ttl)
+
+		value, err := r.Record.Value()
+		require.NoError(t, err)
+		require.Equal(t, path.IPFSNamespace, value.Namespace())
+		t.Log("ipns name:", r.Name, "value:", value)
+	}
+}
+
+func TestIPNSRecordsConfig(t *testing.T) {
+	value, err := path.NewPath("/ipfs/bafkqaaa/foo")
+	require.NoError(t, err)
+	eol := time.Now().Add(time.Hour).Truncate(time.Second)
+	embed := true
+
+	cfg := random.IPNSConfig{
+		KeyType:        crypto.Secp256k1,
+		Value:          value,
+		EOL:            eol,
+		TTL:            time.Minute,
+		Seq:            42,
+		EmbedPublicKey: &embed,
+	}
+	r := random.IPNSRecords(1, cfg)[0]
+	require.NoError(t, ipns.ValidateWithName(r.Record, r.Name))
+	require.Equal(t, crypto.Secp256k1, int(r.PrivKey.Type()))
+
+	gotValue, err := r.Record.Value()
+	require.NoError(t, err)
+	require.Equal(t, value.String(), gotValue.String())
+	gotEOL, err := r.Record.Validity()
+	require.NoError(t, err)
+	require.True(t, eol.Equal(gotEOL))
+	seq, err := r.Record.Sequence()
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), seq)
+	pubKey, err := r.Record.PubKey()
+	require.NoError(t, err)
+	require.True(t, pubKey.Equals(r.PrivKey.GetPublic()))
+
+	cfg = random.DefaultIPNSConfig()
+	cfg.KeyType = crypto.ECDSA
+	cfg.V1Compatible = false
+	r = random.IPNSRecords(1, cfg)[0]
+	require.NoError(t, ipns.ValidateWithName(r.Record, r.Name))
+	_, err = r.Record.PubKey()
+	require.NoError(t, err, "expected embedded public key for ECDSA")
+}
+
+func TestIPNSRecordsFaults(t *testing.T) {
+	cfg := random.DefaultIPNSConfig()
+	cfg.Expired = true
+	r := random.IPNSRecords(1, cfg)[0]
+	require.ErrorIs(t, ipns.ValidateWithName(r.Record, r.Name), ipns.ErrExpiredRecord)
+
+	cfg = random.DefaultIPNSConfig()
+	cfg.FutureDated = true
+	r = random.IPNSRecords(1, cfg)[0]
+	require.NoError(t, ipns.ValidateWithName(r.Record, r.Name))
+	eol, err := r.Record.Validity()
+	require.NoError(t, err)
+	require.Greater(t, eol.Year(), time.Now().Year()+99)
+
+	cfg = random.DefaultIPNSConfig()
+	cfg.BadSignature = true
+	r = random.IPNSRecords(1, cfg)[0]
+	require.ErrorIs(t, ipns.ValidateWithName(r.Record, r.Name), ipns.ErrSignature)
+
+	cfg = random.DefaultIPNSConfig()
+	cfg.Expired = true
+	cfg.FutureDated = true
+	require.Panics(t, func() {
+		random.IPNSRecords(1, cfg)
+	})
+}
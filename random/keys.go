@@ -0,0 +1,253 @@
+package random
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// MixedKeyTypes can be given as the key type to IdentityOfType and
+	// PeersOfType to select a random key type for each identity generated.
+	MixedKeyTypes = -1
+
+	// DefaultRSABits is the RSA key size used when 0 bits are specified.
+	DefaultRSABits = 2048
+)
+
+// RSAKeyPoolSize is the number of pre-generated RSA keys, for each key size,
+// that RSA identities are selected from. Generating RSA keys is slow, so keys
+// are generated once per test binary and reused. PeersOfType generates
+// additional pooled keys when more than RSAKeyPoolSize RSA peers are needed.
+var RSAKeyPoolSize = 32
+
+// KeyTypes are the libp2p key types that identities can be generated for.
+var KeyTypes = []int{crypto.Ed25519, crypto.Secp256k1, crypto.ECDSA, crypto.RSA}
+
+type rsaKeyID struct {
+	bits  int
+	index int
+}
+
+var rsaPool = struct {
+	mutex sync.Mutex
+	keys  map[rsaKeyID]*rsa.PrivateKey
+}{
+	keys: map[rsaKeyID]*rsa.PrivateKey{},
+}
+
+// IdentityOfType returns a random unique peer ID, private key, and public key
+// for the given libp2p key type, such as crypto.RSA or crypto.Secp256k1. The
+// bits argument is the key size for RSA keys and is ignored for other key
+// types; 0 selects DefaultRSABits. If keyType is MixedKeyTypes then the key type
+// is chosen at random from KeyTypes.
+//
+// RSA keys are taken from a pool of RSAKeyPoolSize pre-generated keys, which
+// is shared by all tests in the binary. Successive RSA identities take
+// successive keys from the pool, starting at a key determined by the seed, so
+// they are distinct until the pool has been used up.
+func IdentityOfType(keyType, bits int) (peer.ID, crypto.PrivKey, crypto.PubKey) {
+	rng := NewRand()
+	keyType = selectKeyType(rng, keyType)
+	var privKey crypto.PrivKey
+	var pubKey crypto.PubKey
+	if keyType == crypto.RSA {
+		privKey, pubKey = rsaPoolKey(rsaBits(bits), nextRSAKeyIndex())
+	} else {
+		privKey, pubKey = generateKey(rng, keyType)
+	}
+	peerID, err := peer.IDFromPublicKey(pubKey)
+	if err != nil {
+		panic(err)
+	}
+	return peerID, privKey, pubKey
+}
+
+// PeersOfType returns a slice of n random unique peer IDs with keys of the
+// given libp2p key type. The keyType and bits arguments are the same as for
+// IdentityOfType.
+func PeersOfType(n, keyType, bits int) []peer.ID {
+	peerIDs := make([]peer.ID, n)
+	rng := NewRand()
+	var rsaIndexes []int
+	for i := range n {
+		var pubKey crypto.PubKey
+		if kt := selectKeyType(rng, keyType); kt == crypto.RSA {
+			if rsaIndexes == nil {
+				rsaIndexes = rng.Perm(max(n, RSAKeyPoolSize))
+			}
+			_, pubKey = rsaPoolKey(rsaBits(bits), rsaIndexes[0])
+			rsaIndexes = rsaIndexes[1:]
+		} else {
+			_, pubKey = generateKey(rng, kt)
+		}
+		peerID, err := peer.IDFromPublicKey(pubKey)
+		if err != nil {
+			panic(err)
+		}
+		peerIDs[i] = peerID
+	}
+	return peerIDs
+}
+
+// PeerFromRand returns a random Ed25519 peer ID, like those returned by Peers,
+// that is determined only by the output of rng.
+func PeerFromRand(rng *rand.Rand) peer.ID {
+	_, pubKey := generateKey(rng, crypto.Ed25519)
+	peerID, err := peer.IDFromPublicKey(pubKey)
+	if err != nil {
+		panic(err)
+	}
+	return peerID
+}
+
+func selectKeyType(rng *rand.Rand, keyType int) int {
+	if keyType == MixedKeyTypes {
+		return KeyTypes[rng.Intn(len(KeyTypes))]
+	}
+	return keyType
+}
+
+func rsaBits(bits int) int {
+	if bits == 0 {
+		return DefaultRSABits
+	}
+	if bits < crypto.MinRsaKeyBits {
+		panic(crypto.ErrRsaKeyTooSmall)
+	}
+	return bits
+}
+
+// generateKey generates a non-RSA key pair that is determined only by the
+// output of rng. Secp256k1 and ECDSA keys are created directly from random
+// scalars, since libp2p and the standard library may ignore the random source
+// for these key types.
+func generateKey(rng *rand.Rand, keyType int) (crypto.PrivKey, crypto.PubKey) {
+	var stdKey any
+	switch keyType {
+	case crypto.Secp256k1:
+		key, err := secp256k1.GeneratePrivateKeyFromRand(rng)
+		if err != nil {
+			panic(err)
+		}
+		stdKey = key
+	case crypto.ECDSA:
+		var b [32]byte
+		for {
+			rng.Read(b[:])
+			key, err := ecdsa.ParseRawPrivateKey(crypto.ECDSACurve, b[:])
+			if err == nil {
+				stdKey = key
+				break
+			}
+		}
+	default:
+		privKey, pubKey, err := crypto.GenerateKeyPairWithReader(keyType, 0, rng)
+		if err != nil {
+			panic(err)
+		}
+		return privKey, pubKey
+	}
+	privKey, pubKey, err := crypto.KeyPairFromStdKey(stdKey)
+	if err != nil {
+		panic(err)
+	}
+	return privKey, pubKey
+}
+
+// nextRSAKeyIndex returns the index in the pool of the next RSA identity's key.
+func nextRSAKeyIndex() int {
+	return int(uint64(rsaKeyIndex.Add(1)) % uint64(RSAKeyPoolSize))
+}
+
+// rsaPoolKey returns the RSA key at the given index in the pool of keys of the
+// given size, generating the key if it is not already in the pool.
+func rsaPoolKey(bits, index int) (crypto.PrivKey, crypto.PubKey) {
+	rsaPool.mutex.Lock()
+	defer rsaPool.mutex.Unlock()
+
+	id := rsaKeyID{bits: bits, index: index}
+	key, ok := rsaPool.keys[id]
+	if !ok {
+		// Each pooled key has its own fixed seed, so the pool contents are the
+		// same regardless of the order in which keys are requested.
+		var err error
+		key, err = generateRSAKey(NewSeededRand(int64(bits)<<32|int64(index)), bits)
+		if err != nil {
+			panic(err)
+		}
+		rsaPool.keys[id] = key
+	}
+
+	privKey, pubKey, err := crypto.KeyPairFromStdKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return privKey, pubKey
+}
+
+// generateRSAKey generates an RSA key that is determined only by the output of
+// rng. The standard library's rsa.GenerateKey deliberately does not produce
+// the same key from the same random source.
+func generateRSAKey(rng *rand.Rand, bits int) (*rsa.PrivateKey, error) {
+	const e = 65537
+	one := big.NewInt(1)
+	for {
+		p := randomPrime(rng, bits-bits/2)
+		q := randomPrime(rng, bits/2)
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits {
+			continue
+		}
+		pm1 := new(big.Int).Sub(p, one)
+		qm1 := new(big.Int).Sub(q, one)
+		totient := new(big.Int).Mul(pm1, qm1)
+		d := new(big.Int).ModInverse(big.NewInt(e), totient)
+		if d == nil {
+			continue
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: e},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		key.Precompute()
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("generated invalid rsa key: %w", err)
+		}
+		return key, nil
+	}
+}
+
+// randomPrime returns a prime with the top two bits set, so that the product
+// of two such primes has exactly the sum of their bit lengths.
+func randomPrime(rng *rand.Rand, bits int) *big.Int {
+	b := make([]byte, (bits+7)/8)
+	excess := uint(len(b)*8 - bits)
+	p := new(big.Int)
+	for {
+		rng.Read(b)
+		b[0] &= byte(0xff >> excess)
+		if excess < 7 {
+			b[0] |= 0xc0 >> excess
+		} else {
+			b[0] |= 0x01
+			b[1] |= 0x80
+		}
+		b[len(b)-1] |= 1
+		p.SetBytes(b)
+		if p.ProbablyPrime(0) {
+			return p
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package random_test
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityOfType(t *testing.T) {
+	for _, keyType := range random.KeyTypes {
+		id, privKey, pubKey := random.IdentityOfType(keyType, 0)
+		require.Equal(t, keyType, int(privKey.Type()))
+		require.Equal(t, pubKey, privKey.GetPublic())
+
+		testID, err := peer.IDFromPrivateKey(privKey)
+		require.NoError(t, err)
+		require.Equal(t, id, testID)
+
+		sig, err := privKey.Sign([]byte("hello"))
+		require.NoError(t, err)
+		ok, err := pubKey.Verify([]byte("hello"), sig)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		extracted, err := id.ExtractPublicKey()
+		switch keyType {
+		case crypto.Ed25519, crypto.Secp256k1:
+			require.NoError(t, err, "expected inline public key in peer ID")
+			require.True(t, extracted.Equals(pubKey))
+		default:
+			require.ErrorIs(t, err, peer.ErrNoPublicKey, "expected hashed peer ID")
+		}
+		t.Logf("%s peerID: %s", privKey.Type(), id)
+	}
+
+	require.Panics(t, func() {
+		random.IdentityOfType(crypto.RSA, 1024)
+	})
+}
+
+func TestIdentityOfTypeUnique(t *testing.T) {
+	for _, keyType := range random.KeyTypes {
+		seen := make(map[peer.ID]struct{})
+		for range 8 {
+			id, _, _ := random.IdentityOfType(keyType, 0)
+			_, found := seen[id]
+			require.False(t, found, "duplicate %d peer ID", keyType)
+			seen[id] = struct{}{}
+		}
+	}
+}
+
+func TestRSAKeyPool(t *testing.T) {
+	orig := random.RSAKeyPoolSize
+	random.RSAKeyPoolSize = 2
+	defer func() { random.RSAKeyPoolSize = orig }()
+
+	// Identities take successive keys from the pool, so keys are reused
+	// only once the pool has been used up.
+	id1, _, _ := random.IdentityOfType(crypto.RSA, 0)
+	id2, _, _ := random.IdentityOfType(crypto.RSA, 0)
+	id3, _, _ := random.IdentityOfType(crypto.RSA, 0)
+	require.NotEqual(t, id1, id2)
+	require.Equal(t, id1, id3)
+
+	// PeersOfType takes distinct keys, even when there are more peers than
+	// keys in the pool.
+	peerIDs := random.PeersOfType(3, crypto.RSA, 0)
+	require.Len(t, peerIDs, 3)
+	require.NotEqual(t, peerIDs[0], peerIDs[1])
+	require.NotEqual(t, peerIDs[0], peerIDs[2])
+	require.NotEqual(t, peerIDs[1], peerIDs[2])
+}
+
+func TestIdentityOfTypeSeed(t *testing.T) {
+	initSeed := random.Seed()
+	for _, keyType := range random.KeyTypes {
+		random.SetSeed(initSeed)
+		id1, _, _ := random.IdentityOfType(keyType, 0)
+		random.SetSeed(initSeed)
+		id2, _, _ := random.IdentityOfType(keyType, 0)
+		require.Equal(t, id1, id2)
+	}
+
+	random.SetSeed(initSeed)
+	peers1 := random.PeersOfType(8, random.MixedKeyTypes, 0)
+	random.SetSeed(initSeed)
+	peers2 := random.PeersOfType(8, random.MixedKeyTypes, 0)
+	require.Equal(t, peers1, peers2)
+}
+
+func TestPeersOfType(t *testing.T) {
+	for _, keyType := range random.KeyTypes {
+		peerIDs := random.PeersOfType(3, keyType, 0)
+		require.Len(t, peerIDs, 3)
+		seen := make(map[peer.ID]struct{}, len(peerIDs))
+		for _, peerID := range peerIDs {
+			require.NoError(t, peerID.Validate())
+			_, found := seen[peerID]
+			require.False(t, found, "duplicate peer ID")
+			seen[peerID] = struct{}{}
+		}
+	}
+
+	keyTypes := make(map[int]struct{})
+	for _, peerID := range random.PeersOfType(48, random.MixedKeyTypes, 0) {
+		pubKey, err := peerID.ExtractPublicKey()
+		if err != nil {
+			// RSA and ECDSA public keys are not inlined in peer ID.
+			continue
+		}
+		keyTypes[int(pubKey.Type())] = struct{}{}
+	}
+	require.Len(t, keyTypes, 2, "expected Ed25519 and Secp256k1 peer IDs")
+}
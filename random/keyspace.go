@@ -0,0 +1,95 @@
+package random
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+	"math/rand"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// keyspaceKey returns the position of a key in the Kademlia keyspace, which is
+// the SHA-256 hash of the key's bytes.
+func keyspaceKey(key []byte) [sha256.Size]byte {
+	return sha256.Sum256(key)
+}
+
+// commonPrefixLen returns the number of leading bits that are the same in a
+// and b.
+func commonPrefixLen(a, b [sha256.Size]byte) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return len(a) * 8
+}
+
+// PeersNear returns n random unique peer IDs whose keyspace positions share
+// exactly cpl leading bits with the position of the target peer ID. The XOR
+// distance of each returned peer from the target therefore has exactly cpl
+// leading zero bits.
+//
+// The peer IDs are found by generating random peer IDs until one has the
+// required position, which takes about 2^(cpl+1) attempts per peer. Generating
+// a peer ID only requires hashing, so peers with a cpl of up to about 20 are
+// found quickly. The peer IDs are sha2-256 multihashes, like those of RSA
+// keys, and have no corresponding private keys.
+func PeersNear(target peer.ID, cpl, n int) []peer.ID {
+	return peersAtCpl(NewRand(), keyspaceKey([]byte(target)), cpl, n, make(map[peer.ID]struct{}, n))
+}
+
+// PeersNearCid is the same as PeersNear, but the target is the keyspace
+// position of a CID. This is the position of the CID's multihash, which is
+// where DHT provider records for the CID are stored.
+func PeersNearCid(target cid.Cid, cpl, n int) []peer.ID {
+	return peersAtCpl(NewRand(), keyspaceKey(target.Hash()), cpl, n, make(map[peer.ID]struct{}, n))
+}
+
+// RoutingTablePeers returns peers that fill the k-buckets of a Kademlia routing
+// table whose local peer is target. The number of peers in bucket i is given by
+// bucketSizes[i], and every peer in bucket i has a keyspace position that
+// shares exactly i leading bits with the position of target.
+//
+// Peers are found the same way as by PeersNear, so buckets beyond about 20 are
+// slow to fill.
+func RoutingTablePeers(target peer.ID, bucketSizes []int) [][]peer.ID {
+	rng := NewRand()
+	targetKey := keyspaceKey([]byte(target))
+	seen := make(map[peer.ID]struct{})
+	buckets := make([][]peer.ID, len(bucketSizes))
+	for cpl, size := range bucketSizes {
+		buckets[cpl] = peersAtCpl(rng, targetKey, cpl, size, seen)
+	}
+	return buckets
+}
+
+// peersAtCpl returns n unique peer IDs, that are not in seen, whose keyspace
+// position shares exactly cpl leading bits with target. Each returned peer ID
+// is added to seen.
+func peersAtCpl(rng *rand.Rand, target [sha256.Size]byte, cpl, n int, seen map[peer.ID]struct{}) []peer.ID {
+	if cpl < 0 || cpl >= sha256.Size*8 {
+		panic(fmt.Sprintf("common prefix length out of range, must be between 0 and %d", sha256.Size*8-1))
+	}
+	peerIDs := make([]peer.ID, 0, n)
+	// A peer ID is a multihash of a 32 byte random digest.
+	id := make([]byte, 2+sha256.Size)
+	id[0] = multihash.SHA2_256
+	id[1] = sha256.Size
+	for len(peerIDs) < n {
+		rng.Read(id[2:])
+		if commonPrefixLen(target, keyspaceKey(id)) != cpl {
+			continue
+		}
+		peerID := peer.ID(id)
+		if _, ok := seen[peerID]; ok {
+			continue
+		}
+		seen[peerID] = struct{}{}
+		peerIDs = append(peerIDs, peerID)
+	}
+	return peerIDs
+}
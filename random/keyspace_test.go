@@ -0,0 +1,75 @@
+package random_test
+
+import (
+	"crypto/sha256"
+	"math/bits"
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func cpl(a, b []byte) int {
+	ka := sha256.Sum256(a)
+	kb := sha256.Sum256(b)
+	for i := range ka {
+		if x := ka[i] ^ kb[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return 256
+}
+
+func TestRoutingTablePeers(t *testing.T) {
+	target := random.Peers(1)[0]
+	bucketSizes := []int{20, 20, 20, 10, 5, 0, 3, 1, 1, 1, 1, 1, 1}
+	buckets := random.RoutingTablePeers(target, bucketSizes)
+	require.Len(t, buckets, len(bucketSizes))
+
+	seen := make(map[peer.ID]struct{})
+	for i, bucket := range buckets {
+		require.Len(t, bucket, bucketSizes[i])
+		for _, peerID := range bucket {
+			require.NoError(t, peerID.Validate())
+			require.Equal(t, i, cpl([]byte(target), []byte(peerID)))
+			_, dup := seen[peerID]
+			require.False(t, dup, "duplicate peer ID")
+			seen[peerID] = struct{}{}
+		}
+	}
+
+	require.Panics(t, func() {
+		random.RoutingTablePeers(target, make([]int, 257))
+	})
+}
+
+func TestPeersNear(t *testing.T) {
+	target := random.Peers(1)[0]
+	for _, c := range []int{0, 1, 8, 15} {
+		peerIDs := random.PeersNear(target, c, 5)
+		require.Len(t, peerIDs, 5)
+		for _, peerID := range peerIDs {
+			require.Equal(t, c, cpl([]byte(target), []byte(peerID)))
+		}
+	}
+
+	targetCid := random.Cids(1)[0]
+	for _, peerID := range random.PeersNearCid(targetCid, 12, 3) {
+		require.Equal(t, 12, cpl(targetCid.Hash(), []byte(peerID)))
+	}
+
+	require.Panics(t, func() {
+		random.PeersNear(target, -1, 1)
+	})
+}
+
+func TestPeersNearSeed(t *testing.T) {
+	target := random.Peers(1)[0]
+	initSeed := random.Seed()
+	random.SetSeed(initSeed)
+	peers1 := random.PeersNear(target, 10, 4)
+	random.SetSeed(initSeed)
+	peers2 := random.PeersNear(target, 10, 4)
+	require.Equal(t, peers1, peers2)
+}
@@ -0,0 +1,461 @@
+package random
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multibase"
+)
+
+// Host is the kind of host component at the start of a generated multiaddr.
+type Host int
+
+const (
+	// HostIP4 is an /ip4 address.
+	HostIP4 Host = iota
+	// HostIP6 is an /ip6 address.
+	HostIP6
+	// HostDNS is a /dns name.
+	HostDNS
+	// HostDNS4 is a /dns4 name.
+	HostDNS4
+	// HostDNS6 is a /dns6 name.
+	HostDNS6
+	// HostDNSAddr is a /dnsaddr name. A /dnsaddr multiaddr has no transport.
+	HostDNSAddr
+)
+
+// Transport is the kind of transport components following the host component
+// of a generated multiaddr.
+type Transport int
+
+const (
+	// TransportTCP is /tcp/<port>.
+	TransportTCP Transport = iota
+	// TransportQUIC is /udp/<port>/quic-v1.
+	TransportQUIC
+	// TransportWebTransport is /udp/<port>/quic-v1/webtransport followed by
+	// two /certhash components.
+	TransportWebTransport
+	// TransportWebRTCDirect is /udp/<port>/webrtc-direct/certhash/<hash>.
+	TransportWebRTCDirect
+	// TransportWS is /tcp/<port>/ws.
+	TransportWS
+	// TransportWSS is /tcp/<port>/wss.
+	TransportWSS
+	// TransportTLSWS is /tcp/<port>/tls/sni/<name>/ws.
+	TransportTLSWS
+	// TransportHTTP is /tcp/<port>/http.
+	TransportHTTP
+)
+
+// AddrClass is the class of IP address used in the /ip4 and /ip6 components
+// of generated multiaddrs.
+type AddrClass int
+
+const (
+	// AnyAddr is any address with no zero or 255 octets for IPv4, and any
+	// global unicast address for IPv6. This may be public or private.
+	AnyAddr AddrClass = iota
+	// PublicAddr is an address that is publicly routable.
+	PublicAddr
+	// PrivateAddr is an RFC 1918 IPv4 address or a unique local IPv6 address.
+	PrivateAddr
+	// LoopbackAddr is an address in 127.0.0.0/8 or ::1.
+	LoopbackAddr
+	// LinkLocalAddr is an address in 169.254.0.0/16 or fe80::/10.
+	LinkLocalAddr
+	// UnspecifiedAddr is 0.0.0.0 or ::.
+	UnspecifiedAddr
+	// NAT64Addr is a public IPv4 address embedded in the 64:ff9b::/96 IPv6
+	// prefix. There are no IPv4 NAT64 addresses.
+	NAT64Addr
+	// ReservedAddr is an address in a documentation or otherwise reserved
+	// range that is not routable.
+	ReservedAddr
+)
+
+var (
+	nonPublicPrefixes4 = mustParsePrefixes(
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+		"172.16.0.0/12", "192.0.0.0/24", "192.0.2.0/24", "192.88.99.0/24", "192.168.0.0/16",
+		"198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4")
+	// nonPublicPrefixes6 are the IANA special-purpose IPv6 prefixes, including
+	// those outside of 2000::/3 that random global unicast addresses are
+	// generated in.
+	nonPublicPrefixes6 = mustParsePrefixes(
+		"::/128", "::1/128", "::ffff:0:0/96", "64:ff9b::/96", "64:ff9b:1::/48",
+		"100::/64", "2001::/23", "2001:db8::/32", "2002::/16", "3fff::/20",
+		"5f00::/16", "fc00::/7", "fe80::/10", "ff00::/8")
+
+	classPrefixes4 = map[AddrClass][]netip.Prefix{
+		PrivateAddr:     mustParsePrefixes("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"),
+		LoopbackAddr:    mustParsePrefixes("127.0.0.0/8"),
+		LinkLocalAddr:   mustParsePrefixes("169.254.0.0/16"),
+		UnspecifiedAddr: mustParsePrefixes("0.0.0.0/32"),
+		ReservedAddr:    mustParsePrefixes("192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24", "240.0.0.0/5"),
+	}
+	classPrefixes6 = map[AddrClass][]netip.Prefix{
+		PrivateAddr:     mustParsePrefixes("fc00::/7"),
+		LoopbackAddr:    mustParsePrefixes("::1/128"),
+		LinkLocalAddr:   mustParsePrefixes("fe80::/10"),
+		UnspecifiedAddr: mustParsePrefixes("::/128"),
+		ReservedAddr:    mustParsePrefixes("2001:db8::/32"),
+	}
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+)
+
+// MultiaddrConfig contains settings for generating random multiaddrs. Each
+// multiaddr is composed of a host, a transport, an optional relay
+// /p2p/<relayID>/p2p-circuit, and an optional trailing /p2p/<peerID>.
+type MultiaddrConfig struct {
+	// Hosts maps each kind of host to its relative weight. A kind of host
+	// with a greater weight is chosen more often.
+	Hosts map[Host]int
+	// Transports maps each kind of transport to its relative weight.
+	Transports map[Transport]int
+	// Circuit is the fraction, from 0 to 1, of multiaddrs that are relay
+	// addresses ending with /p2p/<relayID>/p2p-circuit.
+	Circuit float64
+	// P2P is the fraction, from 0 to 1, of multiaddrs that end with
+	// /p2p/<peerID>.
+	P2P float64
+	// PeerID is the peer ID used in /p2p/<peerID>. If empty, a random peer ID
+	// is used for each multiaddr.
+	PeerID peer.ID
+	// Class is the class of IPv4 and IPv6 addresses generated.
+	Class AddrClass
+	// Prefix, if valid, is the CIDR prefix that IP addresses of the same
+	// address family are generated in. Class still applies to addresses of the
+	// other family.
+	Prefix netip.Prefix
+}
+
+// DefaultMultiaddrConfig returns settings for generating /ip4/.../tcp/...
+// multiaddrs, the same as Multiaddrs.
+func DefaultMultiaddrConfig() MultiaddrConfig {
+	return MultiaddrConfig{
+		Hosts:      map[Host]int{HostIP4: 1},
+		Transports: map[Transport]int{TransportTCP: 1},
+	}
+}
+
+// MixedMultiaddrConfig returns settings for generating multiaddrs with an
+// even mix of all hosts and transports, where half of the multiaddrs end with
+// /p2p/<peerID> and some are relay addresses.
+func MixedMultiaddrConfig() MultiaddrConfig {
+	cfg := MultiaddrConfig{
+		Hosts:      make(map[Host]int),
+		Transports: make(map[Transport]int),
+		Circuit:    0.1,
+		P2P:        0.5,
+	}
+	for h := HostIP4; h <= HostDNSAddr; h++ {
+		cfg.Hosts[h] = 1
+	}
+	for tpt := TransportTCP; tpt <= TransportHTTP; tpt++ {
+		cfg.Transports[tpt] = 1
+	}
+	return cfg
+}
+
+// MultiaddrsWithConfig returns a slice of n random unique Multiaddrs created
+// according to the provided configuration.
+func MultiaddrsWithConfig(n int, cfg MultiaddrConfig) []multiaddr.Multiaddr {
+	if err := cfg.validate(); err != nil {
+		panic(err)
+	}
+	hosts := newWeighted(cfg.Hosts)
+	transports := newWeighted(cfg.Transports)
+
+	rng := NewRand()
+	maddrs := make([]multiaddr.Multiaddr, 0, n)
+	addrSet := make(map[string]struct{}, n)
+	for len(maddrs) < n {
+		var b strings.Builder
+		cfg.writeAddr(&b, rng, hosts.pick(rng), transports.pick(rng))
+		addr := b.String()
+		if _, ok := addrSet[addr]; ok {
+			continue
+		}
+		addrSet[addr] = struct{}{}
+
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			panic(err)
+		}
+		maddrs = append(maddrs, maddr)
+	}
+	return maddrs
+}
+
+// MultiaddrFromRand returns a random Multiaddr created according to the
+// provided configuration, that is determined only by the output of rng.
+func MultiaddrFromRand(rng *rand.Rand, cfg MultiaddrConfig) multiaddr.Multiaddr {
+	if err := cfg.validate(); err != nil {
+		panic(err)
+	}
+	var b strings.Builder
+	cfg.writeAddr(&b, rng, newWeighted(cfg.Hosts).pick(rng), newWeighted(cfg.Transports).pick(rng))
+	maddr, err := multiaddr.NewMultiaddr(b.String())
+	if err != nil {
+		panic(err)
+	}
+	return maddr
+}
+
+func (cfg *MultiaddrConfig) validate() error {
+	if err := validateWeights(cfg.Hosts); err != nil {
+		return fmt.Errorf("hosts: %w", err)
+	}
+	if err := validateWeights(cfg.Transports); err != nil {
+		return fmt.Errorf("transports: %w", err)
+	}
+	if cfg.Circuit < 0 || cfg.Circuit > 1 {
+		return errors.New("circuit out of range, must be between 0 and 1")
+	}
+	if cfg.P2P < 0 || cfg.P2P > 1 {
+		return errors.New("p2p out of range, must be between 0 and 1")
+	}
+	if cfg.Class < AnyAddr || cfg.Class > ReservedAddr {
+		return errors.New("unknown address class")
+	}
+	if cfg.Class == NAT64Addr && cfg.Hosts[HostIP4] > 0 && !(cfg.Prefix.IsValid() && cfg.Prefix.Addr().Is4()) {
+		return errors.New("nat64 address class requires ip4 prefix when generating ip4 hosts")
+	}
+	return nil
+}
+
+func (cfg *MultiaddrConfig) writeAddr(b *strings.Builder, rng *rand.Rand, host Host, tpt Transport) {
+	var name string
+	switch host {
+	case HostIP4:
+		fmt.Fprintf(b, "/ip4/%s", cfg.randomIP(rng, false))
+	case HostIP6:
+		fmt.Fprintf(b, "/ip6/%s", cfg.randomIP(rng, true))
+	default:
+		name = randomDNSName(rng)
+		switch host {
+		case HostDNS:
+			b.WriteString("/dns/")
+		case HostDNS4:
+			b.WriteString("/dns4/")
+		case HostDNS6:
+			b.WriteString("/dns6/")
+		case HostDNSAddr:
+			b.WriteString("/dnsaddr/")
+		}
+		b.WriteString(name)
+	}
+
+	if host != HostDNSAddr {
+		writeTransport(b, rng, tpt, name)
+	}
+
+	if rng.Float64() < cfg.Circuit {
+		fmt.Fprintf(b, "/p2p/%s/p2p-circuit", PeerFromRand(rng))
+	}
+	if rng.Float64() < cfg.P2P {
+		peerID := cfg.PeerID
+		if peerID == "" {
+			peerID = PeerFromRand(rng)
+		}
+		fmt.Fprintf(b, "/p2p/%s", peerID)
+	}
+}
+
+func writeTransport(b *strings.Builder, rng *rand.Rand, tpt Transport, name string) {
+	port := rng.Intn(maxTcpPort-minTcpPort) + minTcpPort
+	switch tpt {
+	case TransportTCP:
+		fmt.Fprintf(b, "/tcp/%d", port)
+	case TransportQUIC:
+		fmt.Fprintf(b, "/udp/%d/quic-v1", port)
+	case TransportWebTransport:
+		fmt.Fprintf(b, "/udp/%d/quic-v1/webtransport/certhash/%s/certhash/%s", port, randomCerthash(rng), randomCerthash(rng))
+	case TransportWebRTCDirect:
+		fmt.Fprintf(b, "/udp/%d/webrtc-direct/certhash/%s", port, randomCerthash(rng))
+	case TransportWS:
+		fmt.Fprintf(b, "/tcp/%d/ws", port)
+	case TransportWSS:
+		fmt.Fprintf(b, "/tcp/%d/wss", port)
+	case TransportTLSWS:
+		if name == "" {
+			name = randomDNSName(rng)
+		}
+		fmt.Fprintf(b, "/tcp/%d/tls/sni/%s/ws", port, name)
+	case TransportHTTP:
+		fmt.Fprintf(b, "/tcp/%d/http", port)
+	}
+}
+
+// randomIP returns a random IPv4 or IPv6 address in the configured prefix or
+// address class.
+func (cfg *MultiaddrConfig) randomIP(rng *rand.Rand, ip6 bool) netip.Addr {
+	if cfg.Prefix.IsValid() && cfg.Prefix.Addr().Is6() == ip6 {
+		return randomAddrInPrefix(rng, cfg.Prefix)
+	}
+
+	switch cfg.Class {
+	case AnyAddr:
+		if ip6 {
+			return randomIP6(rng)
+		}
+		return randomIP4(rng)
+	case PublicAddr:
+		if ip6 {
+			return randomPublicIP6(rng)
+		}
+		return randomPublicIP4(rng)
+	case NAT64Addr:
+		ip := nat64Prefix.Addr().As16()
+		ip4 := randomPublicIP4(rng).As4()
+		copy(ip[12:], ip4[:])
+		return netip.AddrFrom16(ip)
+	}
+
+	prefixes := classPrefixes4[cfg.Class]
+	if ip6 {
+		prefixes = classPrefixes6[cfg.Class]
+	}
+	return randomAddrInPrefix(rng, prefixes[rng.Intn(len(prefixes))])
+}
+
+func randomIP4(rng *rand.Rand) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(rng.Intn(254) + 1), byte(rng.Intn(254) + 1), byte(rng.Intn(254) + 1), byte(rng.Intn(254) + 1)})
+}
+
+// randomIP6 returns a random global unicast address in 2000::/3.
+func randomIP6(rng *rand.Rand) netip.Addr {
+	var ip [16]byte
+	rng.Read(ip[:])
+	ip[0] = 0x20 | ip[0]&0x1f
+	return netip.AddrFrom16(ip)
+}
+
+func randomPublicIP4(rng *rand.Rand) netip.Addr {
+	for {
+		ip := randomIP4(rng)
+		if !prefixesContain(nonPublicPrefixes4, ip) {
+			return ip
+		}
+	}
+}
+
+func randomPublicIP6(rng *rand.Rand) netip.Addr {
+	for {
+		ip := randomIP6(rng)
+		if !prefixesContain(nonPublicPrefixes6, ip) {
+			return ip
+		}
+	}
+}
+
+// randomAddrInPrefix returns an address with the prefix bits of the given
+// prefix, and random bits for the rest of the address.
+func randomAddrInPrefix(rng *rand.Rand, prefix netip.Prefix) netip.Addr {
+	addr := prefix.Masked().Addr()
+	ip := addr.AsSlice()
+	rnd := make([]byte, len(ip))
+	rng.Read(rnd)
+	bits := prefix.Bits()
+	for i := range ip {
+		switch {
+		case bits >= 8:
+			bits -= 8
+		case bits > 0:
+			mask := byte(0xff >> bits)
+			ip[i] |= rnd[i] & mask
+			bits = 0
+		default:
+			ip[i] = rnd[i]
+		}
+	}
+	addr, _ = netip.AddrFromSlice(ip)
+	return addr
+}
+
+func prefixesContain(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParsePrefixes(prefixes ...string) []netip.Prefix {
+	parsed := make([]netip.Prefix, len(prefixes))
+	for i, prefix := range prefixes {
+		parsed[i] = netip.MustParsePrefix(prefix)
+	}
+	return parsed
+}
+
+func randomDNSName(rng *rand.Rand) string {
+	const nameLen = 8
+	var name [nameLen]byte
+	for i := range nameLen {
+		name[i] = byte(rng.Intn(26) + 'a')
+	}
+	return string(name[:]) + ".example.com"
+}
+
+// randomCerthash returns a multibase encoded sha2-256 multihash of random
+// bytes, as used in a /certhash component.
+func randomCerthash(rng *rand.Rand) string {
+	s, err := multibase.Encode(multibase.Base64url, MultihashFromRand(rng))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// weighted selects values at random according to their relative weights.
+type weighted[T ~int] struct {
+	values []T
+	cumul  []int
+}
+
+func newWeighted[T ~int](weights map[T]int) weighted[T] {
+	// Sort the values so that selection does not depend on map order.
+	var w weighted[T]
+	for v, weight := range weights {
+		if weight > 0 {
+			w.values = append(w.values, v)
+		}
+	}
+	slices.Sort(w.values)
+	total := 0
+	for _, v := range w.values {
+		total += weights[v]
+		w.cumul = append(w.cumul, total)
+	}
+	return w
+}
+
+func (w weighted[T]) pick(rng *rand.Rand) T {
+	n := rng.Intn(w.cumul[len(w.cumul)-1])
+	i, _ := slices.BinarySearch(w.cumul, n+1)
+	return w.values[i]
+}
+
+func validateWeights[T ~int](weights map[T]int) error {
+	var total int
+	for _, weight := range weights {
+		if weight < 0 {
+			return errors.New("weight must be 0 or greater")
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errors.New("at least one weight must be greater than 0")
+	}
+	return nil
+}
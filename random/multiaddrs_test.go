@@ -0,0 +1,165 @@
+package random_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiaddrsWithConfig(t *testing.T) {
+	ms := random.MultiaddrsWithConfig(10, random.DefaultMultiaddrConfig())
+	require.Len(t, ms, 10)
+	for _, ma := range ms {
+		protos := ma.Protocols()
+		require.Len(t, protos, 2)
+		require.Equal(t, multiaddr.P_IP4, protos[0].Code)
+		require.Equal(t, multiaddr.P_TCP, protos[1].Code)
+	}
+
+	const n = 500
+	ms = random.MultiaddrsWithConfig(n, random.MixedMultiaddrConfig())
+	require.Len(t, ms, n)
+	seen := make(map[int]struct{})
+	unique := make(map[string]struct{}, n)
+	for _, ma := range ms {
+		for _, p := range ma.Protocols() {
+			seen[p.Code] = struct{}{}
+		}
+		unique[ma.String()] = struct{}{}
+	}
+	require.Len(t, unique, n)
+	for _, code := range []int{
+		multiaddr.P_IP4, multiaddr.P_IP6, multiaddr.P_DNS, multiaddr.P_DNS4, multiaddr.P_DNS6, multiaddr.P_DNSADDR,
+		multiaddr.P_TCP, multiaddr.P_UDP, multiaddr.P_QUIC_V1, multiaddr.P_WEBTRANSPORT, multiaddr.P_CERTHASH,
+		multiaddr.P_WEBRTC_DIRECT, multiaddr.P_WS, multiaddr.P_WSS, multiaddr.P_TLS, multiaddr.P_SNI,
+		multiaddr.P_HTTP, multiaddr.P_P2P, multiaddr.P_CIRCUIT,
+	} {
+		_, ok := seen[code]
+		require.True(t, ok, "missing protocol %s", multiaddr.ProtocolWithCode(code).Name)
+	}
+}
+
+func TestMultiaddrsWithConfigP2P(t *testing.T) {
+	peerID := random.Peers(1)[0]
+	cfg := random.MultiaddrConfig{
+		Hosts:      map[random.Host]int{random.HostIP6: 1},
+		Transports: map[random.Transport]int{random.TransportQUIC: 3, random.TransportWebTransport: 1},
+		Circuit:    1,
+		P2P:        1,
+		PeerID:     peerID,
+	}
+	for _, ma := range random.MultiaddrsWithConfig(5, cfg) {
+		require.True(t, strings.HasPrefix(ma.String(), "/ip6/"))
+		transport, id := peer.SplitAddr(ma)
+		require.Equal(t, peerID, id)
+		_, err := transport.ValueForProtocol(multiaddr.P_CIRCUIT)
+		require.NoError(t, err)
+		t.Log("multiaddr:", ma)
+	}
+
+	cfg.P2P = 1.5
+	require.Panics(t, func() {
+		random.MultiaddrsWithConfig(1, cfg)
+	})
+	cfg.P2P = 0
+	cfg.Transports = nil
+	require.Panics(t, func() {
+		random.MultiaddrsWithConfig(1, cfg)
+	})
+}
+
+func TestMultiaddrsWithConfigSeed(t *testing.T) {
+	initSeed := random.Seed()
+	random.SetSeed(initSeed)
+	ms1 := random.MultiaddrsWithConfig(20, random.MixedMultiaddrConfig())
+	random.SetSeed(initSeed)
+	ms2 := random.MultiaddrsWithConfig(20, random.MixedMultiaddrConfig())
+	require.Equal(t, ms1, ms2)
+}
+
+func TestMultiaddrsAddrClass(t *testing.T) {
+	cfg := random.DefaultMultiaddrConfig()
+	cfg.Hosts = map[random.Host]int{random.HostIP4: 1, random.HostIP6: 1}
+
+	special6 := []netip.Prefix{
+		netip.MustParsePrefix("2001::/32"),     // Teredo
+		netip.MustParsePrefix("2001:2::/48"),   // benchmarking
+		netip.MustParsePrefix("2001:20::/28"),  // ORCHIDv2
+		netip.MustParsePrefix("2001:db8::/32"), // documentation
+		netip.MustParsePrefix("2002::/16"),     // 6to4
+	}
+	cfg.Class = random.PublicAddr
+	for _, ma := range random.MultiaddrsWithConfig(500, cfg) {
+		require.True(t, manet.IsPublicAddr(ma), "not public: %s", ma)
+		ip, err := manet.ToIP(ma)
+		require.NoError(t, err)
+		addr, _ := netip.AddrFromSlice(ip)
+		for _, prefix := range special6 {
+			require.False(t, prefix.Contains(addr), "special-purpose: %s", ma)
+		}
+	}
+	cfg.Class = random.PrivateAddr
+	for _, ma := range random.MultiaddrsWithConfig(100, cfg) {
+		require.True(t, manet.IsPrivateAddr(ma), "not private: %s", ma)
+		require.False(t, manet.IsIPLoopback(ma), "loopback: %s", ma)
+	}
+	cfg.Class = random.LoopbackAddr
+	for _, ma := range random.MultiaddrsWithConfig(100, cfg) {
+		require.True(t, manet.IsIPLoopback(ma), "not loopback: %s", ma)
+	}
+	cfg.Class = random.LinkLocalAddr
+	for _, ma := range random.MultiaddrsWithConfig(100, cfg) {
+		require.True(t, manet.IsIP6LinkLocal(ma) || strings.HasPrefix(ma.String(), "/ip4/169.254."), "not link-local: %s", ma)
+	}
+	cfg.Class = random.UnspecifiedAddr
+	for _, ma := range random.MultiaddrsWithConfig(100, cfg) {
+		require.True(t, manet.IsIPUnspecified(ma), "not unspecified: %s", ma)
+	}
+	cfg.Class = random.ReservedAddr
+	for _, ma := range random.MultiaddrsWithConfig(100, cfg) {
+		require.False(t, manet.IsPublicAddr(ma), "public: %s", ma)
+		require.False(t, manet.IsPrivateAddr(ma), "private: %s", ma)
+	}
+
+	cfg.Class = random.NAT64Addr
+	require.Panics(t, func() {
+		random.MultiaddrsWithConfig(1, cfg)
+	})
+	cfg.Hosts = map[random.Host]int{random.HostIP6: 1}
+	for _, ma := range random.MultiaddrsWithConfig(100, cfg) {
+		require.True(t, manet.IsNAT64IPv4ConvertedIPv6Addr(ma), "not nat64: %s", ma)
+	}
+}
+
+func TestMultiaddrsPrefix(t *testing.T) {
+	cfg := random.DefaultMultiaddrConfig()
+	cfg.Hosts = map[random.Host]int{random.HostIP4: 1, random.HostIP6: 1}
+	cfg.Prefix = netip.MustParsePrefix("100.64.12.0/22")
+	cfg.Class = random.LoopbackAddr
+	for _, ma := range random.MultiaddrsWithConfig(200, cfg) {
+		ip, err := manet.ToIP(ma)
+		require.NoError(t, err)
+		addr, _ := netip.AddrFromSlice(ip)
+		addr = addr.Unmap()
+		if addr.Is4() {
+			require.True(t, cfg.Prefix.Contains(addr), "not in prefix: %s", ma)
+		} else {
+			require.True(t, addr.IsLoopback(), "not loopback: %s", ma)
+		}
+	}
+
+	cfg.Prefix = netip.MustParsePrefix("2001:db8:1234::/125")
+	cfg.Hosts = map[random.Host]int{random.HostIP6: 1}
+	for _, ma := range random.MultiaddrsWithConfig(50, cfg) {
+		ip, err := manet.ToIP(ma)
+		require.NoError(t, err)
+		addr, _ := netip.AddrFromSlice(ip)
+		require.True(t, cfg.Prefix.Contains(addr), "not in prefix: %s", ma)
+	}
+}
@@ -0,0 +1,150 @@
+package random
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// ProviderConfig contains settings for creating random provider records.
+type ProviderConfig struct {
+	// Providers is the number of unique providers that records are created
+	// for. Each multihash is provided by providers chosen from these.
+	Providers int
+	// Addrs is the number of ipv4 addresses of each provider.
+	Addrs int
+	// MinProvidersPerKey is the minimum number of providers of each multihash.
+	MinProvidersPerKey int
+	// MaxProvidersPerKey is the maximum number of providers of each multihash.
+	// It must not be greater than Providers.
+	MaxProvidersPerKey int
+	// ZipfS, when greater than 1, is the s parameter of a Zipf distribution of
+	// the number of providers per multihash, so that most multihashes have
+	// close to MinProvidersPerKey providers and a few have many. Otherwise the
+	// number of providers per multihash is uniformly distributed.
+	ZipfS float64
+	// ContextIDSize is the number of random bytes in each record's context
+	// ID. Each provider uses the same context ID for all of its records. No
+	// context ID is created if 0.
+	ContextIDSize int
+	// MetadataSize is the number of random bytes in each record's metadata.
+	// Each provider uses the same metadata for all of its records. No metadata
+	// is created if 0.
+	MetadataSize int
+	// MinTTL is the minimum time from now until a record expires. A negative
+	// value creates records that have already expired.
+	MinTTL time.Duration
+	// MaxTTL is the maximum time from now until a record expires.
+	MaxTTL time.Duration
+}
+
+// ProviderRecord is a record of a provider that provides the content
+// identified by a multihash.
+type ProviderRecord struct {
+	Multihash multihash.Multihash
+	Provider  peer.AddrInfo
+	ContextID []byte
+	Metadata  []byte
+	Expiry    time.Time
+}
+
+// DefaultProviderConfig returns default settings for creating random provider
+// records.
+func DefaultProviderConfig() ProviderConfig {
+	return ProviderConfig{
+		Providers:          10,
+		Addrs:              2,
+		MinProvidersPerKey: 1,
+		MaxProvidersPerKey: 3,
+		ContextIDSize:      16,
+		MetadataSize:       8,
+		MinTTL:             time.Hour,
+		MaxTTL:             24 * time.Hour,
+	}
+}
+
+// ProviderRecords returns provider records for n random unique multihashes,
+// created according to the provided configuration. The records for each
+// multihash are adjacent in the returned slice.
+func ProviderRecords(n int, cfg ProviderConfig) []ProviderRecord {
+	if err := cfg.validate(); err != nil {
+		panic(err)
+	}
+	rng := NewRand()
+
+	type provider struct {
+		addrInfo  peer.AddrInfo
+		contextID []byte
+		metadata  []byte
+	}
+	providers := make([]provider, cfg.Providers)
+	for i, addrInfo := range AddrInfos(cfg.Providers, cfg.Addrs) {
+		providers[i].addrInfo = addrInfo
+		if cfg.ContextIDSize != 0 {
+			providers[i].contextID = make([]byte, cfg.ContextIDSize)
+			rng.Read(providers[i].contextID)
+		}
+		if cfg.MetadataSize != 0 {
+			providers[i].metadata = make([]byte, cfg.MetadataSize)
+			rng.Read(providers[i].metadata)
+		}
+	}
+
+	var zipf *rand.Zipf
+	if cfg.ZipfS > 1 && cfg.MaxProvidersPerKey > cfg.MinProvidersPerKey {
+		zipf = rand.NewZipf(rng, cfg.ZipfS, 1, uint64(cfg.MaxProvidersPerKey-cfg.MinProvidersPerKey))
+	}
+
+	now := time.Now()
+	var recs []ProviderRecord
+	for _, mh := range Multihashes(n) {
+		count := cfg.MinProvidersPerKey
+		if zipf != nil {
+			count += int(zipf.Uint64())
+		} else if cfg.MaxProvidersPerKey > cfg.MinProvidersPerKey {
+			count += rng.Intn(cfg.MaxProvidersPerKey - cfg.MinProvidersPerKey + 1)
+		}
+		for _, i := range rng.Perm(cfg.Providers)[:count] {
+			ttl := cfg.MinTTL
+			if cfg.MaxTTL > cfg.MinTTL {
+				ttl += time.Duration(rng.Int63n(int64(cfg.MaxTTL - cfg.MinTTL)))
+			}
+			recs = append(recs, ProviderRecord{
+				Multihash: mh,
+				Provider:  providers[i].addrInfo,
+				ContextID: providers[i].contextID,
+				Metadata:  providers[i].metadata,
+				Expiry:    now.Add(ttl),
+			})
+		}
+	}
+	return recs
+}
+
+func (cfg *ProviderConfig) validate() error {
+	if cfg.Providers < 1 {
+		return errors.New("providers must be at least 1")
+	}
+	if cfg.Addrs < 0 {
+		return errors.New("addrs must be 0 or greater")
+	}
+	if cfg.MinProvidersPerKey < 0 {
+		return errors.New("minimum providers per key must be 0 or greater")
+	}
+	if cfg.MaxProvidersPerKey < cfg.MinProvidersPerKey {
+		return errors.New("maximum providers per key is less than minimum providers per key")
+	}
+	if cfg.MaxProvidersPerKey > cfg.Providers {
+		return errors.New("maximum providers per key is greater than providers")
+	}
+	if cfg.ContextIDSize < 0 || cfg.MetadataSize < 0 {
+		return errors.New("context ID and metadata sizes must be 0 or greater")
+	}
+	if cfg.MaxTTL < cfg.MinTTL {
+		return errors.New("maximum ttl is less than minimum ttl")
+	}
+	return nil
+}
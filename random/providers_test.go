@@ -0,0 +1,86 @@
+package random_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRecords(t *testing.T) {
+	const numKeys = 50
+	cfg := random.DefaultProviderConfig()
+	recs := random.ProviderRecords(numKeys, cfg)
+
+	perKey := make(map[string][]random.ProviderRecord)
+	providers := make(map[peer.ID]struct{})
+	for _, rec := range recs {
+		_, err := multihash.Decode(rec.Multihash)
+		require.NoError(t, err)
+		require.Len(t, rec.Provider.Addrs, cfg.Addrs)
+		require.Len(t, rec.ContextID, cfg.ContextIDSize)
+		require.Len(t, rec.Metadata, cfg.MetadataSize)
+		require.True(t, rec.Expiry.After(time.Now()))
+		require.True(t, rec.Expiry.Before(time.Now().Add(cfg.MaxTTL)))
+		perKey[string(rec.Multihash)] = append(perKey[string(rec.Multihash)], rec)
+		providers[rec.Provider.ID] = struct{}{}
+	}
+	require.Len(t, perKey, numKeys)
+	require.LessOrEqual(t, len(providers), cfg.Providers)
+	for _, keyRecs := range perKey {
+		require.GreaterOrEqual(t, len(keyRecs), cfg.MinProvidersPerKey)
+		require.LessOrEqual(t, len(keyRecs), cfg.MaxProvidersPerKey)
+		seen := make(map[peer.ID]struct{})
+		for _, rec := range keyRecs {
+			_, dup := seen[rec.Provider.ID]
+			require.False(t, dup, "duplicate provider for multihash")
+			seen[rec.Provider.ID] = struct{}{}
+		}
+	}
+}
+
+func TestProviderRecordsZipf(t *testing.T) {
+	const numKeys = 200
+	cfg := random.DefaultProviderConfig()
+	cfg.Providers = 50
+	cfg.MinProvidersPerKey = 1
+	cfg.MaxProvidersPerKey = 50
+	cfg.ZipfS = 2
+	cfg.ContextIDSize = 0
+	cfg.MetadataSize = 0
+	cfg.MinTTL = -time.Hour
+	cfg.MaxTTL = -time.Minute
+
+	counts := make(map[string]int)
+	for _, rec := range random.ProviderRecords(numKeys, cfg) {
+		require.Nil(t, rec.ContextID)
+		require.Nil(t, rec.Metadata)
+		require.True(t, rec.Expiry.Before(time.Now()), "expected expired record")
+		counts[string(rec.Multihash)]++
+	}
+	var few int
+	for _, count := range counts {
+		if count <= 2 {
+			few++
+		}
+	}
+	require.Greater(t, few, numKeys/2, "expected most keys to have few providers")
+}
+
+func TestProviderRecordsValidation(t *testing.T) {
+	cfg := random.DefaultProviderConfig()
+	cfg.MaxProvidersPerKey = cfg.Providers + 1
+	require.Panics(t, func() { random.ProviderRecords(1, cfg) })
+
+	cfg = random.DefaultProviderConfig()
+	cfg.MinProvidersPerKey = 4
+	cfg.MaxProvidersPerKey = 3
+	require.Panics(t, func() { random.ProviderRecords(1, cfg) })
+
+	cfg = random.DefaultProviderConfig()
+	cfg.MaxTTL = 0
+	require.Panics(t, func() { random.ProviderRecords(1, cfg) })
+}
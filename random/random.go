@@ -19,6 +19,7 @@ var (
 	initSeed     int64
 	globalSeed   atomic.Int64
 	globalSeqGen atomic.Uint64
+	rsaKeyIndex  atomic.Int64
 )
 
 const (
@@ -56,6 +57,7 @@ func SetSeed(seed int64) {
 	globalSeed.Store(seed)
 	rng := rand.New(rand.NewSource(seed))
 	globalSeqGen.Store(rng.Uint64())
+	rsaKeyIndex.Store(rng.Int63())
 }
 
 // Addrs returns a slice of n random unique IPv4 addresses.
@@ -77,19 +79,11 @@ func Addrs(n int) []string {
 // DnsAddrs returns a slice of n random unique DNS addresses in the format
 // "xxxxxxxx.example.com:port".
 func DnsAddrs(n int) []string {
-	const (
-		nameLen     = 8
-		lowerAsciiA = 97
-	)
 	addrs := make([]string, n)
 	addrSet := make(map[string]struct{}, n)
 	rng := NewRand()
 	for i := 0; i < n; i++ {
-		var name [nameLen]byte
-		for j := range nameLen {
-			name[j] = byte(rng.Intn(26) + lowerAsciiA)
-		}
-		addr := fmt.Sprintf("/dns4/%s.example.com/tcp/%d", name, rng.Intn(maxTcpPort-minTcpPort)+minTcpPort)
+		addr := fmt.Sprintf("/dns4/%s/tcp/%d", randomDNSName(rng), rng.Intn(maxTcpPort-minTcpPort)+minTcpPort)
 		if _, ok := addrSet[addr]; ok {
 			i--
 			continue
@@ -120,28 +114,20 @@ func Cids(n int) []cid.Cid {
 	cids := make([]cid.Cid, 0, n)
 	rng := NewRand()
 	for len(cids) < n {
-		var b [32]byte
-		rng.Read(b[:])
-		h, err := multihash.Encode(b[:], multihash.SHA2_256)
-		if err != nil {
-			panic(err)
-		}
-		cids = append(cids, cid.NewCidV1(uint64(multicodec.DagJson), h))
+		cids = append(cids, CidFromRand(rng))
 	}
 	return cids
 }
 
+// CidFromRand returns a random CID, like those returned by Cids, that is
+// determined only by the output of rng.
+func CidFromRand(rng *rand.Rand) cid.Cid {
+	return cid.NewCidV1(uint64(multicodec.DagJson), MultihashFromRand(rng))
+}
+
 // Identity returns a random unique peer ID, private key, and public key.
 func Identity() (peer.ID, crypto.PrivKey, crypto.PubKey) {
-	privKey, pubKey, err := crypto.GenerateKeyPairWithReader(crypto.Ed25519, 256, NewRand())
-	if err != nil {
-		panic(err)
-	}
-	peerID, err := peer.IDFromPublicKey(pubKey)
-	if err != nil {
-		panic(err)
-	}
-	return peerID, privKey, pubKey
+	return IdentityOfType(crypto.Ed25519, 0)
 }
 
 func multiaddrs(n int, addrsFunc func(int) []string) []multiaddr.Multiaddr {
@@ -192,33 +178,26 @@ func Multihashes(n int) []multihash.Multihash {
 	rng := NewRand()
 	mhashes := make([]multihash.Multihash, 0, n)
 	for len(mhashes) < n {
-		var b [32]byte
-		rng.Read(b[:])
-		h, err := multihash.Encode(b[:], multihash.SHA2_256)
-		if err != nil {
-			panic(err.Error())
-		}
-		mhashes = append(mhashes, h)
+		mhashes = append(mhashes, MultihashFromRand(rng))
 	}
 	return mhashes
 }
 
+// MultihashFromRand returns a random Multihash, like those returned by
+// Multihashes, that is determined only by the output of rng.
+func MultihashFromRand(rng *rand.Rand) multihash.Multihash {
+	var b [32]byte
+	rng.Read(b[:])
+	h, err := multihash.Encode(b[:], multihash.SHA2_256)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
 // Peers returns a slice of n random peer IDs.
 func Peers(n int) []peer.ID {
-	peerIDs := make([]peer.ID, n)
-	rng := NewRand()
-	for i := range n {
-		_, publicKey, err := crypto.GenerateEd25519Key(rng)
-		if err != nil {
-			panic(err)
-		}
-		peerID, err := peer.IDFromPublicKey(publicKey)
-		if err != nil {
-			panic(err)
-		}
-		peerIDs[i] = peerID
-	}
-	return peerIDs
+	return PeersOfType(n, crypto.Ed25519, 0)
 }
 
 func addrInfos(numPeers, numAddrs int, multiaddrsFunc func(int) []multiaddr.Multiaddr) []peer.AddrInfo {
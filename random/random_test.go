@@ -119,6 +119,29 @@ func TestPeers(t *testing.T) {
 	}
 }
 
+func TestFromRand(t *testing.T) {
+	rng1 := random.NewSeededRand(42)
+	rng2 := random.NewSeededRand(42)
+
+	c := random.CidFromRand(rng1)
+	require.True(t, strings.HasPrefix(c.String(), "baguqeera"))
+	require.Equal(t, c, random.CidFromRand(rng2))
+
+	mh := random.MultihashFromRand(rng1)
+	decoded, err := multihash.Decode(mh)
+	require.NoError(t, err)
+	require.Equal(t, uint64(multihash.SHA2_256), decoded.Code)
+	require.Equal(t, mh, random.MultihashFromRand(rng2))
+
+	peerID := random.PeerFromRand(rng1)
+	require.True(t, strings.HasPrefix(peerID.String(), "12D3Koo"))
+	require.Equal(t, peerID, random.PeerFromRand(rng2))
+
+	cfg := random.MixedMultiaddrConfig()
+	ma := random.MultiaddrFromRand(rng1, cfg)
+	require.Equal(t, ma, random.MultiaddrFromRand(rng2, cfg))
+}
+
 // TestSeed tests that setting seed back to its initial value generates the
 // same results.
 func TestSeed(t *testing.T) {
@@ -0,0 +1,131 @@
+package random
+
+import (
+	"crypto/rsa"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+)
+
+// SignedPeerRecord is a peer record sealed in an envelope that is signed by the
+// private key of the record's peer.
+type SignedPeerRecord struct {
+	Envelope *record.Envelope
+	Record   *peer.PeerRecord
+	PrivKey  crypto.PrivKey
+}
+
+// SignedPeerRecords returns a slice of n signed peer records, each for a
+// unique Ed25519 peer identity with numAddrs ipv4 addresses. Each record has a
+// unique sequence number.
+func SignedPeerRecords(n, numAddrs int) []SignedPeerRecord {
+	return SignedPeerRecordsOfType(n, numAddrs, crypto.Ed25519, 0)
+}
+
+// SignedPeerRecordsOfType is the same as SignedPeerRecords, but creates peer
+// identities of the given libp2p key type. The keyType and bits arguments are
+// the same as for IdentityOfType.
+func SignedPeerRecordsOfType(n, numAddrs, keyType, bits int) []SignedPeerRecord {
+	recs := make([]SignedPeerRecord, n)
+	for i := range n {
+		peerID, privKey, _ := IdentityOfType(keyType, bits)
+		rec := &peer.PeerRecord{
+			PeerID: peerID,
+			Addrs:  Multiaddrs(numAddrs),
+			Seq:    SequenceNext(),
+		}
+		recs[i] = SignedPeerRecord{
+			Envelope: seal(rec, privKey),
+			Record:   rec,
+			PrivKey:  privKey,
+		}
+	}
+	return recs
+}
+
+// Stale returns a validly signed copy of the record that has a lower sequence
+// number than the original, as if it were an older record from the same peer.
+func (r SignedPeerRecord) Stale() SignedPeerRecord {
+	if r.Record.Seq == 0 {
+		panic("peer record sequence number is 0")
+	}
+	rec := &peer.PeerRecord{
+		PeerID: r.Record.PeerID,
+		Addrs:  r.Record.Addrs,
+		Seq:    r.Record.Seq - 1 - uint64(NewRand().Int63n(int64(min(r.Record.Seq, 1<<16)))),
+	}
+	return SignedPeerRecord{
+		Envelope: seal(rec, r.PrivKey),
+		Record:   rec,
+		PrivKey:  r.PrivKey,
+	}
+}
+
+// WrongSigner returns a copy of the record that is sealed in an envelope signed
+// by a different random identity, with the same key type and size. The
+// envelope signature is valid, but the signer does not match the record's peer
+// ID.
+func (r SignedPeerRecord) WrongSigner() SignedPeerRecord {
+	keyType, bits := int(r.PrivKey.Type()), keyBits(r.PrivKey)
+	_, privKey, _ := IdentityOfType(keyType, bits)
+	for privKey.Equals(r.PrivKey) {
+		// Pooled RSA keys are reused once the pool has been used up.
+		_, privKey, _ = IdentityOfType(keyType, bits)
+	}
+	return SignedPeerRecord{
+		Envelope: seal(r.Record, privKey),
+		Record:   r.Record,
+		PrivKey:  privKey,
+	}
+}
+
+// keyBits returns the size of an RSA key, or 0 for other key types.
+func keyBits(privKey crypto.PrivKey) int {
+	stdKey, err := crypto.PrivKeyToStdKey(privKey)
+	if err != nil {
+		panic(err)
+	}
+	if rsaKey, ok := stdKey.(*rsa.PrivateKey); ok {
+		return rsaKey.N.BitLen()
+	}
+	return 0
+}
+
+// Tampered returns a copy of the record whose envelope payload has been
+// modified, by adding an address, after the envelope was signed. The envelope
+// signature does not verify.
+func (r SignedPeerRecord) Tampered() SignedPeerRecord {
+	rec := &peer.PeerRecord{
+		PeerID: r.Record.PeerID,
+		Addrs:  append(r.Record.Addrs[:len(r.Record.Addrs):len(r.Record.Addrs)], Multiaddrs(1)...),
+		Seq:    r.Record.Seq,
+	}
+	payload, err := rec.MarshalRecord()
+	if err != nil {
+		panic(err)
+	}
+	// Copy the envelope by round-tripping it, since it cannot be copied by value.
+	data, err := r.Envelope.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	env, err := record.UnmarshalEnvelope(data)
+	if err != nil {
+		panic(err)
+	}
+	env.RawPayload = payload
+	return SignedPeerRecord{
+		Envelope: env,
+		Record:   rec,
+		PrivKey:  r.PrivKey,
+	}
+}
+
+func seal(rec record.Record, privKey crypto.PrivKey) *record.Envelope {
+	env, err := record.Seal(rec, privKey)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
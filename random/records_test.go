@@ -0,0 +1,81 @@
+package random_test
+
+import (
+	"crypto/rsa"
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/stretchr/testify/require"
+)
+
+func consumePeerRecord(t *testing.T, env *record.Envelope) (*peer.PeerRecord, peer.ID, error) {
+	data, err := env.Marshal()
+	require.NoError(t, err)
+	env, rec, err := record.ConsumeEnvelope(data, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		return nil, "", err
+	}
+	signer, err := peer.IDFromPublicKey(env.PublicKey)
+	require.NoError(t, err)
+	return rec.(*peer.PeerRecord), signer, nil
+}
+
+func TestSignedPeerRecords(t *testing.T) {
+	const numAddrs = 3
+	recs := random.SignedPeerRecords(3, numAddrs)
+	require.Len(t, recs, 3)
+	for _, r := range recs {
+		rec, signer, err := consumePeerRecord(t, r.Envelope)
+		require.NoError(t, err)
+		require.True(t, rec.Equal(r.Record))
+		require.Equal(t, rec.PeerID, signer)
+		require.Len(t, rec.Addrs, numAddrs)
+		require.Equal(t, crypto.Ed25519, int(r.PrivKey.Type()))
+	}
+	require.NotEqual(t, recs[0].Record.Seq, recs[1].Record.Seq)
+
+	recs = random.SignedPeerRecordsOfType(1, numAddrs, crypto.Secp256k1, 0)
+	_, _, err := consumePeerRecord(t, recs[0].Envelope)
+	require.NoError(t, err)
+	require.Equal(t, crypto.Secp256k1, int(recs[0].PrivKey.Type()))
+}
+
+func TestWrongSignerKeySize(t *testing.T) {
+	const bits = 2560
+	r := random.SignedPeerRecordsOfType(1, 1, crypto.RSA, bits)[0]
+	wrong := r.WrongSigner()
+	require.False(t, wrong.PrivKey.Equals(r.PrivKey))
+	for _, privKey := range []crypto.PrivKey{r.PrivKey, wrong.PrivKey} {
+		stdKey, err := crypto.PrivKeyToStdKey(privKey)
+		require.NoError(t, err)
+		require.Equal(t, bits, stdKey.(*rsa.PrivateKey).N.BitLen())
+	}
+}
+
+func TestSignedPeerRecordFaults(t *testing.T) {
+	r := random.SignedPeerRecords(1, 2)[0]
+
+	stale := r.Stale()
+	rec, signer, err := consumePeerRecord(t, stale.Envelope)
+	require.NoError(t, err)
+	require.Equal(t, r.Record.PeerID, signer)
+	require.Less(t, rec.Seq, r.Record.Seq)
+
+	wrong := r.WrongSigner()
+	rec, signer, err = consumePeerRecord(t, wrong.Envelope)
+	require.NoError(t, err)
+	require.Equal(t, r.Record.PeerID, rec.PeerID)
+	require.NotEqual(t, rec.PeerID, signer)
+
+	tampered := r.Tampered()
+	_, _, err = consumePeerRecord(t, tampered.Envelope)
+	require.ErrorIs(t, err, record.ErrInvalidSignature)
+	require.Len(t, tampered.Record.Addrs, len(r.Record.Addrs)+1)
+
+	// Original record is unchanged.
+	_, _, err = consumePeerRecord(t, r.Envelope)
+	require.NoError(t, err)
+}
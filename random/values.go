@@ -0,0 +1,329 @@
+package random
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	// defaultMaxLen is the maximum length of strings, slices and maps that do
+	// not have a length set by a struct tag.
+	defaultMaxLen = 8
+	// maxValueDepth is the depth of nested pointers, slices and maps beyond
+	// which values of recursive types are left empty.
+	maxValueDepth = 8
+)
+
+var (
+	cidType       = reflect.TypeFor[cid.Cid]()
+	peerIDType    = reflect.TypeFor[peer.ID]()
+	multiaddrType = reflect.TypeFor[multiaddr.Multiaddr]()
+	multihashType = reflect.TypeFor[multihash.Multihash]()
+)
+
+// Value returns a random value of type T. See Fill for how the value is
+// created.
+func Value[T any]() T {
+	var v T
+	Fill(&v)
+	return v
+}
+
+// Fill sets the value that ptr points to, and all of its exported struct
+// fields, elements, and entries, to random values. It panics if ptr is not a
+// non-nil pointer, or if the value contains a type that cannot be created,
+// such as a channel or func.
+//
+// Fields of type cid.Cid, peer.ID, multiaddr.Multiaddr and multihash.Multihash
+// are set to random valid values. Interface fields are left nil, as are
+// pointers, slices and maps nested deeply enough that they may be recursive.
+//
+// The values of struct fields are constrained by a "random" struct tag that
+// contains comma-separated options:
+//
+//	min=N     minimum value of a number
+//	max=N     maximum value of a number
+//	len=N     exact length of a string, slice or map
+//	minlen=N  minimum length of a string, slice or map
+//	maxlen=N  maximum length of a string, slice or map
+//	-         leave the field unset
+//
+// The min and max options also apply to the elements of a slice, array or map
+// field and to the value that a pointer field points to. For example:
+//
+//	type Advertisement struct {
+//		Provider peer.ID
+//		Addrs    []multiaddr.Multiaddr `random:"minlen=1,maxlen=3"`
+//		Entries  cid.Cid
+//		Metadata []byte `random:"len=16"`
+//		Priority int    `random:"min=0,max=10"`
+//		cache    map[string]int // unexported, left unset
+//	}
+func Fill(ptr any) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		panic(fmt.Sprintf("random: Fill requires a non-nil pointer, got %T", ptr))
+	}
+	f := filler{rng: NewRand()}
+	if err := f.fill(v.Elem(), valueOpts{}, 0); err != nil {
+		panic(err)
+	}
+}
+
+// valueOpts are the constraints set by a "random" struct tag.
+type valueOpts struct {
+	min, max       *float64
+	minLen, maxLen int
+	hasLen         bool
+}
+
+func parseValueOpts(tag string) (valueOpts, bool, error) {
+	var opts valueOpts
+	var minLen, maxLen *int
+	if tag == "" {
+		return opts, false, nil
+	}
+	if tag == "-" {
+		return opts, true, nil
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		name, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return opts, false, fmt.Errorf("random: invalid tag option %q", opt)
+		}
+		switch name {
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return opts, false, fmt.Errorf("random: invalid %s value %q", name, value)
+			}
+			if name == "min" {
+				opts.min = &n
+			} else {
+				opts.max = &n
+			}
+		case "len", "minlen", "maxlen":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return opts, false, fmt.Errorf("random: invalid %s value %q", name, value)
+			}
+			switch name {
+			case "len":
+				minLen, maxLen = &n, &n
+			case "minlen":
+				minLen = &n
+			case "maxlen":
+				maxLen = &n
+			}
+		default:
+			return opts, false, fmt.Errorf("random: unknown tag option %q", name)
+		}
+	}
+	if opts.min != nil && opts.max != nil && *opts.max < *opts.min {
+		return opts, false, fmt.Errorf("random: max %v is less than min %v", *opts.max, *opts.min)
+	}
+	if minLen != nil || maxLen != nil {
+		opts.hasLen = true
+		if minLen != nil {
+			opts.minLen = *minLen
+		}
+		if maxLen != nil {
+			opts.maxLen = *maxLen
+		} else {
+			opts.maxLen = max(opts.minLen, defaultMaxLen)
+		}
+	}
+	if opts.maxLen < opts.minLen {
+		return opts, false, fmt.Errorf("random: maxlen %d is less than minlen %d", opts.maxLen, opts.minLen)
+	}
+	return opts, false, nil
+}
+
+// elem returns the options that apply to elements of a collection.
+func (opts valueOpts) elem() valueOpts {
+	return valueOpts{min: opts.min, max: opts.max}
+}
+
+type filler struct {
+	rng *rand.Rand
+}
+
+func (f *filler) length(opts valueOpts) int {
+	if !opts.hasLen {
+		return f.rng.Intn(defaultMaxLen + 1)
+	}
+	return opts.minLen + f.rng.Intn(opts.maxLen-opts.minLen+1)
+}
+
+func (f *filler) fill(v reflect.Value, opts valueOpts, depth int) error {
+	switch v.Type() {
+	case cidType:
+		v.Set(reflect.ValueOf(CidFromRand(f.rng)))
+		return nil
+	case peerIDType:
+		v.Set(reflect.ValueOf(PeerFromRand(f.rng)))
+		return nil
+	case multiaddrType:
+		v.Set(reflect.ValueOf(MultiaddrFromRand(f.rng, DefaultMultiaddrConfig())))
+		return nil
+	case multihashType:
+		v.Set(reflect.ValueOf(MultihashFromRand(f.rng)))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(f.rng.Intn(2) == 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := v.Type().Bits()
+		lo, hi := int64(-1)<<(bits-1), int64(uint64(1)<<(bits-1)-1)
+		if opts.min != nil {
+			lo = clampInt(*opts.min, lo, hi)
+		}
+		if opts.max != nil {
+			hi = clampInt(*opts.max, lo, hi)
+		}
+		v.SetInt(lo + int64(f.uint64Between(0, uint64(hi)-uint64(lo))))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		lo, hi := uint64(0), uint64(math.MaxUint64)>>(64-v.Type().Bits())
+		if opts.min != nil {
+			lo = clampUint(*opts.min, lo, hi)
+		}
+		if opts.max != nil {
+			hi = clampUint(*opts.max, lo, hi)
+		}
+		v.SetUint(f.uint64Between(lo, hi))
+	case reflect.Float32, reflect.Float64:
+		lo, hi := -1e6, 1e6
+		if opts.min != nil {
+			lo = *opts.min
+			hi = max(hi, lo)
+		}
+		if opts.max != nil {
+			hi = *opts.max
+			lo = min(lo, hi)
+		}
+		v.SetFloat(lo + f.rng.Float64()*(hi-lo))
+	case reflect.String:
+		b := make([]byte, f.length(opts))
+		for i := range b {
+			b[i] = byte('a' + f.rng.Intn(26))
+		}
+		v.SetString(string(b))
+	case reflect.Pointer:
+		if depth >= maxValueDepth {
+			v.SetZero()
+			return nil
+		}
+		p := reflect.New(v.Type().Elem())
+		if err := f.fill(p.Elem(), opts, depth+1); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && opts.min == nil && opts.max == nil {
+			b := make([]byte, f.length(opts))
+			f.rng.Read(b)
+			v.SetBytes(b)
+			return nil
+		}
+		n := f.length(opts)
+		if depth >= maxValueDepth {
+			n = 0
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := range n {
+			if err := f.fill(s.Index(i), opts.elem(), depth+1); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		for i := range v.Len() {
+			if err := f.fill(v.Index(i), opts.elem(), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		n := f.length(opts)
+		if depth >= maxValueDepth {
+			n = 0
+		}
+		m := reflect.MakeMapWithSize(v.Type(), n)
+		// Give up on keys that keep repeating, such as bool keys.
+		for tries := 0; m.Len() < n && tries < 4*n; tries++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := f.fill(key, valueOpts{}, depth+1); err != nil {
+				return err
+			}
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err := f.fill(val, opts.elem(), depth+1); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, val)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldOpts, skip, err := parseValueOpts(field.Tag.Get("random"))
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", t, field.Name, err)
+			}
+			if skip {
+				continue
+			}
+			if err = f.fill(v.Field(i), fieldOpts, depth); err != nil {
+				return err
+			}
+		}
+	case reflect.Interface:
+		// There is no way to know which concrete type to create.
+	default:
+		return fmt.Errorf("random: cannot create value of type %s", v.Type())
+	}
+	return nil
+}
+
+// clampInt returns n rounded to an integer in the range [lo, hi].
+func clampInt(n float64, lo, hi int64) int64 {
+	switch {
+	case n <= float64(lo):
+		return lo
+	case n >= float64(hi):
+		return hi
+	}
+	return int64(n)
+}
+
+// clampUint returns n rounded to an integer in the range [lo, hi].
+func clampUint(n float64, lo, hi uint64) uint64 {
+	switch {
+	case n <= float64(lo):
+		return lo
+	case n >= float64(hi):
+		return hi
+	}
+	return uint64(n)
+}
+
+func (f *filler) uint64Between(lo, hi uint64) uint64 {
+	span := hi - lo
+	if span == math.MaxUint64 {
+		return f.rng.Uint64()
+	}
+	return lo + f.rng.Uint64()%(span+1)
+}
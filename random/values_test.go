@@ -0,0 +1,132 @@
+package random_test
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-test/random"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+type advertisement struct {
+	PreviousID *cid.Cid
+	Provider   peer.ID
+	Addrs      []multiaddr.Multiaddr `random:"minlen=1,maxlen=3"`
+	Entries    cid.Cid
+	Hashes     []multihash.Multihash `random:"len=4"`
+	ContextID  []byte                `random:"len=16"`
+	Priority   int                   `random:"min=-5,max=10"`
+	Weights    map[string]uint8      `random:"maxlen=4,min=1,max=3"`
+	Scores     [3]float64            `random:"min=0.5,max=1"`
+	Name       string                `random:"minlen=2,maxlen=5"`
+	Skipped    string                `random:"-"`
+	Next       *advertisement
+	Any        any
+	unexported int
+}
+
+func TestFill(t *testing.T) {
+	for range 50 {
+		var ad advertisement
+		random.Fill(&ad)
+
+		require.NotNil(t, ad.PreviousID)
+		require.True(t, ad.PreviousID.Defined())
+		require.True(t, ad.Entries.Defined())
+		// CIDs are the same kind as those returned by random.Cids.
+		require.Equal(t, random.Cids(1)[0].Prefix(), ad.Entries.Prefix())
+		require.NoError(t, ad.Provider.Validate())
+		require.NotEmpty(t, ad.Provider)
+		require.GreaterOrEqual(t, len(ad.Addrs), 1)
+		require.LessOrEqual(t, len(ad.Addrs), 3)
+		for _, addr := range ad.Addrs {
+			_, err := multiaddr.NewMultiaddrBytes(addr.Bytes())
+			require.NoError(t, err)
+		}
+		require.Len(t, ad.Hashes, 4)
+		for _, mh := range ad.Hashes {
+			_, err := multihash.Decode(mh)
+			require.NoError(t, err)
+		}
+		require.Len(t, ad.ContextID, 16)
+		require.GreaterOrEqual(t, ad.Priority, -5)
+		require.LessOrEqual(t, ad.Priority, 10)
+		require.LessOrEqual(t, len(ad.Weights), 4)
+		for _, w := range ad.Weights {
+			require.GreaterOrEqual(t, w, uint8(1))
+			require.LessOrEqual(t, w, uint8(3))
+		}
+		for _, s := range ad.Scores {
+			require.GreaterOrEqual(t, s, 0.5)
+			require.LessOrEqual(t, s, 1.0)
+		}
+		require.GreaterOrEqual(t, len(ad.Name), 2)
+		require.LessOrEqual(t, len(ad.Name), 5)
+		require.Empty(t, ad.Skipped)
+		require.Nil(t, ad.Any)
+		require.Zero(t, ad.unexported)
+
+		// Recursive types end.
+		depth := 0
+		for next := ad.Next; next != nil; next = next.Next {
+			depth++
+		}
+		require.Less(t, depth, 10)
+	}
+}
+
+func TestValue(t *testing.T) {
+	ids := make(map[peer.ID]struct{})
+	for range 20 {
+		ids[random.Value[peer.ID]()] = struct{}{}
+	}
+	require.Len(t, ids, 20)
+
+	cids := random.Value[[]cid.Cid]()
+	for _, c := range cids {
+		require.True(t, c.Defined())
+	}
+
+	ad := random.Value[advertisement]()
+	require.True(t, ad.Entries.Defined())
+}
+
+func TestFillSeed(t *testing.T) {
+	seed := random.Seed()
+	defer random.SetSeed(seed)
+
+	random.SetSeed(7)
+	a := random.Value[advertisement]()
+	random.SetSeed(7)
+	b := random.Value[advertisement]()
+	require.Equal(t, a, b)
+}
+
+func TestFillInvalid(t *testing.T) {
+	var ad advertisement
+	require.Panics(t, func() { random.Fill(ad) })
+	require.Panics(t, func() { random.Fill((*advertisement)(nil)) })
+
+	var badTag struct {
+		N int `random:"min=10,max=1"`
+	}
+	require.Panics(t, func() { random.Fill(&badTag) })
+
+	var badLen struct {
+		S string `random:"maxlen=1,minlen=2"`
+	}
+	require.Panics(t, func() { random.Fill(&badLen) })
+
+	var unknown struct {
+		S string `random:"size=2"`
+	}
+	require.Panics(t, func() { random.Fill(&unknown) })
+
+	var ch struct {
+		C chan int
+	}
+	require.Panics(t, func() { random.Fill(&ch) })
+}